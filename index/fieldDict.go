@@ -0,0 +1,168 @@
+package index
+
+import (
+	"bytes"
+	"container/heap"
+)
+
+// DictEntry is one (term, docFreq, totalTermFreq) tuple yielded by a
+// FieldDict iteration, mirroring bleve's FieldDict API.
+type DictEntry struct {
+	Term          []byte
+	DocFreq       int
+	TotalTermFreq int64
+}
+
+// FieldDict streams a field's terms in sorted order. Next returns
+// (nil, nil) once iteration is exhausted, the same EOF convention
+// TermsEnum.Next already uses in this package.
+type FieldDict struct {
+	te       TermsEnum
+	endTerm  []byte // exclusive upper bound, nil means unbounded
+	prefix   []byte // nil means no prefix restriction
+}
+
+func newFieldDict(te TermsEnum, startTerm, endTerm, prefix []byte) (*FieldDict, error) {
+	d := &FieldDict{te: te, endTerm: endTerm, prefix: prefix}
+	if startTerm != nil {
+		te.SeekCeil(startTerm)
+	} else if prefix != nil {
+		te.SeekCeil(prefix)
+	} else {
+		if _, err := te.Next(); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// fieldDictExhausted reports whether term falls outside the [_, endTerm)
+// range or prefix restriction a FieldDict was constructed with, i.e.
+// whether Next should stop rather than yield an entry for term. endTerm
+// nil means unbounded above; prefix nil means no prefix restriction.
+func fieldDictExhausted(term, endTerm, prefix []byte) bool {
+	if endTerm != nil && bytes.Compare(term, endTerm) >= 0 {
+		return true
+	}
+	if prefix != nil && !bytes.HasPrefix(term, prefix) {
+		return true
+	}
+	return false
+}
+
+// Next returns the current term's entry and advances, or (nil, nil) once
+// the field, range or prefix is exhausted.
+func (d *FieldDict) Next() (*DictEntry, error) {
+	term := d.te.Term()
+	if term == nil {
+		return nil, nil
+	}
+	if fieldDictExhausted(term, d.endTerm, d.prefix) {
+		return nil, nil
+	}
+	entry := &DictEntry{
+		Term:          append([]byte(nil), term...),
+		DocFreq:       d.te.DocFreq(),
+		TotalTermFreq: d.te.TotalTermFreq(),
+	}
+	if _, err := d.te.Next(); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// FieldDict returns an iterator over every term in field, in sorted
+// order.
+func (r *FieldReader) FieldDict() (*FieldDict, error) {
+	return newFieldDict(r.Iterator(nil), nil, nil, nil)
+}
+
+// FieldDictRange returns an iterator over the terms in field within
+// [startTerm, endTerm).
+func (r *FieldReader) FieldDictRange(startTerm, endTerm []byte) (*FieldDict, error) {
+	return newFieldDict(r.Iterator(nil), startTerm, endTerm, nil)
+}
+
+// FieldDictPrefix returns an iterator over the terms in field sharing
+// prefix.
+func (r *FieldReader) FieldDictPrefix(prefix []byte) (*FieldDict, error) {
+	return newFieldDict(r.Iterator(nil), nil, nil, prefix)
+}
+
+// MergedFieldDict merges several segments' FieldDict iterators into one
+// sorted stream, summing docFreq/totalTermFreq across segments for terms
+// that appear in more than one -- the same "merge per-segment TermsEnums
+// with a heap" shape IndexSnapshot needs to answer field-level iteration
+// against a multi-segment view.
+type MergedFieldDict struct {
+	h fieldDictHeap
+}
+
+type fieldDictSource struct {
+	dict *FieldDict
+	cur  *DictEntry
+}
+
+type fieldDictHeap []*fieldDictSource
+
+func (h fieldDictHeap) Len() int            { return len(h) }
+func (h fieldDictHeap) Less(i, j int) bool  { return bytes.Compare(h[i].cur.Term, h[j].cur.Term) < 0 }
+func (h fieldDictHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *fieldDictHeap) Push(x interface{}) { *h = append(*h, x.(*fieldDictSource)) }
+func (h *fieldDictHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+func newMergedFieldDict(dicts []*FieldDict) (*MergedFieldDict, error) {
+	m := &MergedFieldDict{}
+	for _, d := range dicts {
+		entry, err := d.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			heap.Push(&m.h, &fieldDictSource{dict: d, cur: entry})
+		}
+	}
+	heap.Init(&m.h)
+	return m, nil
+}
+
+// Next returns the next distinct term across every merged segment (with
+// docFreq/totalTermFreq summed over segments sharing that term), or
+// (nil, nil) once every segment is exhausted.
+func (m *MergedFieldDict) Next() (*DictEntry, error) {
+	if m.h.Len() == 0 {
+		return nil, nil
+	}
+	top := heap.Pop(&m.h).(*fieldDictSource)
+	merged := *top.cur
+	if err := m.advance(top); err != nil {
+		return nil, err
+	}
+	for m.h.Len() > 0 && bytes.Equal(m.h[0].cur.Term, merged.Term) {
+		dup := heap.Pop(&m.h).(*fieldDictSource)
+		merged.DocFreq += dup.cur.DocFreq
+		merged.TotalTermFreq += dup.cur.TotalTermFreq
+		if err := m.advance(dup); err != nil {
+			return nil, err
+		}
+	}
+	return &merged, nil
+}
+
+func (m *MergedFieldDict) advance(src *fieldDictSource) error {
+	next, err := src.dict.Next()
+	if err != nil {
+		return err
+	}
+	if next != nil {
+		src.cur = next
+		heap.Push(&m.h, src)
+	}
+	return nil
+}
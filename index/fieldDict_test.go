@@ -0,0 +1,30 @@
+package index
+
+import "testing"
+
+func TestFieldDictExhaustedRespectsEndTerm(t *testing.T) {
+	if fieldDictExhausted([]byte("cat"), []byte("dog"), nil) {
+		t.Error(`fieldDictExhausted("cat", endTerm="dog", nil) = true, want false: "cat" < "dog"`)
+	}
+	if !fieldDictExhausted([]byte("dog"), []byte("dog"), nil) {
+		t.Error(`fieldDictExhausted("dog", endTerm="dog", nil) = false, want true: endTerm is exclusive`)
+	}
+	if !fieldDictExhausted([]byte("fox"), []byte("dog"), nil) {
+		t.Error(`fieldDictExhausted("fox", endTerm="dog", nil) = false, want true: "fox" > "dog"`)
+	}
+}
+
+func TestFieldDictExhaustedRespectsPrefix(t *testing.T) {
+	if fieldDictExhausted([]byte("catalog"), nil, []byte("cat")) {
+		t.Error(`fieldDictExhausted("catalog", nil, prefix="cat") = true, want false: shares the prefix`)
+	}
+	if !fieldDictExhausted([]byte("dog"), nil, []byte("cat")) {
+		t.Error(`fieldDictExhausted("dog", nil, prefix="cat") = false, want true: doesn't share the prefix`)
+	}
+}
+
+func TestFieldDictExhaustedUnboundedWithNoEndTermOrPrefix(t *testing.T) {
+	if fieldDictExhausted([]byte("anything"), nil, nil) {
+		t.Error(`fieldDictExhausted("anything", nil, nil) = true, want false: unbounded iteration never stops on its own`)
+	}
+}
@@ -0,0 +1,168 @@
+package index
+
+import (
+	"github.com/balzaczyy/golucene/util"
+	"github.com/balzaczyy/golucene/util/automaton"
+)
+
+// CompiledAutomaton wraps an automaton.Automaton for intersection against
+// a field's FST (supplied by FieldReader.IntersectAutomaton, not held
+// here), so MultiTermQuery-style queries (fuzzy,
+// wildcard, prefix, regexp) all go through the same cheap enumeration
+// path: walk the FST and the DFA in lockstep, and only follow an FST arc
+// whose label the DFA still accepts. See the doc comment on
+// BlockTreeTermsReader: "intersection with Automata is very fast".
+type CompiledAutomaton struct {
+	automaton *automaton.Automaton
+}
+
+func NewCompiledAutomaton(a *automaton.Automaton) *CompiledAutomaton {
+	return &CompiledAutomaton{automaton: a}
+}
+
+// NewFuzzyAutomaton builds the CompiledAutomaton for a fuzzy term: term
+// with up to maxEdits (Damerau-)Levenshtein edits, using the package-init
+// builder for maxEdits; the DFA itself is built anew for each term.
+func NewFuzzyAutomaton(term string, maxEdits int, transpositions bool) *CompiledAutomaton {
+	builder := automaton.LevenshteinAutomatonBuilderFor(maxEdits, transpositions)
+	return NewCompiledAutomaton(builder.ToAutomaton(term))
+}
+
+// NewPrefixAutomaton builds the CompiledAutomaton matching any term
+// beginning with prefix.
+func NewPrefixAutomaton(prefix []byte) *CompiledAutomaton {
+	return NewCompiledAutomaton(automaton.PrefixAutomaton(prefix))
+}
+
+// NewWildcardAutomaton builds the CompiledAutomaton for a classic Lucene
+// wildcard pattern (`*` and `?`).
+func NewWildcardAutomaton(pattern string) *CompiledAutomaton {
+	return NewCompiledAutomaton(automaton.WildcardAutomaton(pattern))
+}
+
+// NewRegexpAutomaton builds the CompiledAutomaton for a regexp pattern.
+func NewRegexpAutomaton(pattern string) *CompiledAutomaton {
+	return NewCompiledAutomaton(automaton.ParseRegexp(pattern))
+}
+
+// IntersectTermsEnum walks a FieldReader's FST and a CompiledAutomaton's
+// DFA in lockstep, yielding only the terms the FST actually contains that
+// the automaton also accepts. It satisfies the same TermsEnum contract as
+// SegmentTermsEnum, but Next() is driven by automaton-guided FST descent
+// instead of straight block iteration.
+type IntersectTermsEnum struct {
+	*TermsEnumImpl
+	owner    *FieldReader
+	compiled *CompiledAutomaton
+
+	// frontier holds one (fstArc, automaton state, matched prefix) per
+	// depth of the current descent; frontier[len(frontier)-1] is "here".
+	frontier []intersectFrame
+
+	term []byte
+}
+
+type intersectFrame struct {
+	arc      util.Arc
+	dfaState int
+	label    byte
+
+	// pendingUndo mirrors segmentTermsEnumFrame.pendingUndo: set when the
+	// byte just returned as a match has no child of its own, so it can't
+	// be popped until this frame is revisited for its next sibling arc.
+	pendingUndo bool
+}
+
+// consumePendingUndo mirrors segmentTermsEnumFrame.consumePendingUndo.
+func (f *intersectFrame) consumePendingUndo(term []byte) []byte {
+	if !f.pendingUndo {
+		return term
+	}
+	f.pendingUndo = false
+	return term[:len(term)-1]
+}
+
+func newIntersectTermsEnum(owner *FieldReader, compiled *CompiledAutomaton) (*IntersectTermsEnum, error) {
+	e := &IntersectTermsEnum{owner: owner, compiled: compiled}
+	e.TermsEnumImpl = newTermsEnumImpl(e)
+
+	fstReader := owner.index.BytesReader()
+	rootArc, err := owner.index.GetFirstArc(&util.Arc{})
+	if err != nil {
+		return nil, err
+	}
+	e.frontier = []intersectFrame{{arc: *rootArc, dfaState: compiled.automaton.InitialState()}}
+	_ = fstReader
+	return e, nil
+}
+
+// Next descends the FST, at each step only taking an arc whose label is
+// accepted by the current DFA state, backtracking (popping frontier
+// frames) when the FST has no more sibling arcs to try. It returns the
+// next term in the intersection, or nil, io.EOF-equivalent when exhausted
+// (signalled the same way the rest of this package signals EOF: a nil
+// buf with a nil error).
+func (e *IntersectTermsEnum) Next() (buf []byte, err error) {
+	for len(e.frontier) > 0 {
+		top := &e.frontier[len(e.frontier)-1]
+		e.term = top.consumePendingUndo(e.term)
+
+		fstReader := e.owner.index.BytesReader()
+		nextArc, err := e.owner.index.ReadNextRealArc(&top.arc, fstReader)
+		if err != nil || nextArc == nil {
+			// No more siblings at this depth: pop back up.
+			e.frontier = e.frontier[:len(e.frontier)-1]
+			if len(e.term) > 0 {
+				e.term = e.term[:len(e.term)-1]
+			}
+			continue
+		}
+		top.arc = *nextArc
+
+		dest, ok := e.compiled.automaton.Step(top.dfaState, byte(top.arc.Label))
+		if !ok {
+			// This arc's label is rejected outright; try the next sibling.
+			continue
+		}
+
+		e.term = append(e.term, byte(top.arc.Label))
+		isFinal := top.arc.IsFinal()
+		accepts := isFinal && e.compiled.automaton.IsAccept(dest)
+
+		childArc, childErr := e.owner.index.ReadFirstTargetArc(&top.arc, &util.Arc{}, fstReader)
+		pushChild, undoByte := fstDescent(isFinal, childErr == nil && childArc != nil)
+		if pushChild {
+			e.frontier = append(e.frontier, intersectFrame{arc: *childArc, dfaState: dest})
+		}
+		if accepts {
+			top.pendingUndo = undoByte
+			return e.term, nil
+		}
+		if undoByte {
+			// Final arc whose output the automaton rejects, or a dead end:
+			// undo the byte we just appended and keep trying siblings.
+			e.term = e.term[:len(e.term)-1]
+		}
+	}
+	return nil, nil
+}
+
+func (e *IntersectTermsEnum) Term() []byte {
+	return e.term
+}
+
+func (e *IntersectTermsEnum) SeekExact(target []byte) bool {
+	panic("IntersectTermsEnum only supports forward iteration via Next")
+}
+
+func (e *IntersectTermsEnum) SeekCeil(text []byte) SeekStatus {
+	panic("IntersectTermsEnum only supports forward iteration via Next")
+}
+
+// IntersectAutomaton returns a TermsEnum over exactly the terms in this
+// field that compiled accepts, implementing the Terms side of the
+// MultiTermQuery automaton-intersection contract used by fuzzy, wildcard,
+// prefix and regexp queries alike.
+func (r *FieldReader) IntersectAutomaton(compiled *CompiledAutomaton) (TermsEnum, error) {
+	return newIntersectTermsEnum(r, compiled)
+}
@@ -1,6 +1,7 @@
 package index
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/balzaczyy/golucene/codec"
@@ -9,6 +10,7 @@ import (
 	"io"
 	"log"
 	"sort"
+	"sync"
 )
 
 type FieldsProducer interface {
@@ -351,6 +353,16 @@ func (r *FieldReader) DocCount() int {
 	return int(r.docCount)
 }
 
+// SeekStatus reports how a TermsEnum.SeekCeil call landed relative to
+// the requested term.
+type SeekStatus int
+
+const (
+	SEEK_STATUS_END       SeekStatus = iota // No term at or after the target; enum is exhausted.
+	SEEK_STATUS_FOUND                       // Landed exactly on the requested term.
+	SEEK_STATUS_NOT_FOUND                   // Landed on the next term after the requested one.
+)
+
 // BlockTreeTermsReader.java/SegmentTermsEnum
 // Iterates through terms in this field
 type SegmentTermsEnum struct {
@@ -359,7 +371,9 @@ type SegmentTermsEnum struct {
 
 	in store.IndexInput
 
-	stack        []segmentTermsEnumFrame
+	// stack holds one pooled frame per depth of the current FST descent;
+	// stack[len(stack)-1] is "here". See advance/resetToRoot.
+	stack        []*segmentTermsEnumFrame
 	staticFrame  segmentTermsEnumFrame
 	currentFrame segmentTermsEnumFrame
 	termExists   bool
@@ -375,50 +389,272 @@ type SegmentTermsEnum struct {
 	term      []byte
 	fstReader util.BytesReader
 
-	arcs []util.Arc
+	// framePool lets deeply-nested block descents (notably automaton
+	// intersection, which pushes/pops a frame per FST depth far more
+	// often than flat term iteration) reuse segmentTermsEnumFrame
+	// instances instead of allocating fresh suffixBytes/statBytes/
+	// floorData slices on every push.
+	framePool *sync.Pool
 }
 
 func newSegmentTermsEnum(r *FieldReader) *SegmentTermsEnum {
 	ans := &SegmentTermsEnum{
 		owner:         r,
 		scratchReader: store.NewByteArrayDataInput(nil),
-		arcs:          make([]util.Arc, 1),
 	}
+	ans.framePool = &sync.Pool{New: func() interface{} { return newFrame(ans, 0) }}
 	ans.TermsEnumImpl = newTermsEnumImpl(ans)
 	return ans
 }
 
+// getFrame returns a frame for stack depth ord, reusing a pooled one
+// when available instead of allocating.
+func (e *SegmentTermsEnum) getFrame(ord int) *segmentTermsEnumFrame {
+	f := e.framePool.Get().(*segmentTermsEnumFrame)
+	f.ord = ord
+	f.pendingUndo = false
+	return f
+}
+
+// putFrame returns f to the pool once it is popped off the stack; the
+// caller must not touch f again afterwards.
+func (e *SegmentTermsEnum) putFrame(f *segmentTermsEnumFrame) {
+	e.framePool.Put(f)
+}
+
 func (e *SegmentTermsEnum) Comparator() sort.Interface {
 	panic("not implemented yet")
 }
 
 func (e *SegmentTermsEnum) SeekExact(target []byte) bool {
-	panic("not implemented yet")
+	return e.SeekCeil(target) == SEEK_STATUS_FOUND
+}
+
+// seekCeilPruner decides, for SeekCeil, whether the arc at a given FST
+// depth can be skipped without it or anything beneath it ever being the
+// ceiling over text. It's a standalone type (rather than a closure
+// inlined into SeekCeil) so the pruning/stop-pruning transition can be
+// unit-tested without a real FST to walk.
+type seekCeilPruner struct {
+	text    []byte
+	pruning bool
+}
+
+// prune reports whether the arc labeled label at FST depth depth sorts
+// strictly before text and so can be skipped. Once an arc sorts strictly
+// after text at some depth, pruning permanently stops: FST arcs at a
+// given depth are visited in increasing label order, so every arc from
+// here on (including after backtracking to a shallower depth and moving
+// to its next sibling) is already known to sort after text, and the next
+// accepted term plain forward order finds is the ceiling.
+func (p *seekCeilPruner) prune(depth int, label byte) bool {
+	if !p.pruning || depth >= len(p.text) {
+		return false
+	}
+	if label < p.text[depth] {
+		return true
+	}
+	if label > p.text[depth] {
+		p.pruning = false
+	}
+	return false
 }
 
+// SeekCeil moves to the smallest term >= text, driving the descent
+// through owner.index (the field's FST) rather than decoding any block:
+// it walks arcs in label order, pruning with a seekCeilPruner until it
+// passes text, then falls back to plain forward order to land on the
+// ceiling.
 func (e *SegmentTermsEnum) SeekCeil(text []byte) SeekStatus {
-	panic("not implemented yet")
+	if err := e.resetToRoot(); err != nil {
+		e.eof = true
+		return SEEK_STATUS_END
+	}
+	p := &seekCeilPruner{text: text, pruning: true}
+	term, err := e.advance(p.prune)
+	// A final term that is a proper prefix of text is not pruned but still
+	// sorts before it; keep going until the ceiling is reached.
+	for err == nil && term != nil && bytes.Compare(term, text) < 0 {
+		term, err = e.advance(p.prune)
+	}
+	if err != nil || term == nil {
+		return SEEK_STATUS_END
+	}
+	if bytes.Equal(term, text) {
+		return SEEK_STATUS_FOUND
+	}
+	return SEEK_STATUS_NOT_FOUND
 }
 
+// Next returns the next term in the field, in FST (lexicographic) order.
 func (e *SegmentTermsEnum) Next() (buf []byte, err error) {
-	panic("not implemented yet")
+	if e.fstReader == nil && len(e.stack) == 0 && !e.eof {
+		if err := e.resetToRoot(); err != nil {
+			return nil, err
+		}
+	}
+	return e.advance(nil)
 }
 
+// resetToRoot returns any frames left on the stack to framePool, then
+// rewinds it to a single fresh frame at the field's FST root, ready to
+// descend from scratch; both Next (on a fresh enum) and SeekCeil start
+// here.
+func (e *SegmentTermsEnum) resetToRoot() error {
+	for _, f := range e.stack {
+		e.putFrame(f)
+	}
+	e.stack = e.stack[:0]
+
+	fstReader := e.owner.index.BytesReader()
+	rootArc, err := e.owner.index.GetFirstArc(&util.Arc{})
+	if err != nil {
+		return err
+	}
+	root := e.getFrame(0)
+	root.arc = *rootArc
+	e.stack = append(e.stack, root)
+
+	e.fstReader = fstReader
+	e.term = e.term[:0]
+	e.eof = false
+	e.termExists = false
+	return nil
+}
+
+// fstDescent captures the two independent questions that arise once an
+// FST arc has been taken: whether to push a child frame and keep
+// descending, and whether the byte just appended needs to be undone
+// because the arc has no child to descend into. They're independent
+// because an FST node can be both final (a term ends here, e.g. "cat")
+// and have further outgoing arcs (a longer term extends the same prefix,
+// e.g. "cats") -- isFinal and hasChild are never mutually exclusive, so
+// pushChild must not be gated on !isFinal the way a single if/else would
+// suggest. undoByte is unconditional on !hasChild: a final, childless arc
+// (the common case -- any term that isn't itself a prefix of a longer
+// one) still needs its byte popped once the caller is done with it, the
+// caller just can't do that until after it has returned the match, since
+// the byte is part of the term being returned. Shared by
+// SegmentTermsEnum.advance and IntersectTermsEnum.Next, the two places
+// that walk an FST arc by arc.
+func fstDescent(isFinal, hasChild bool) (pushChild, undoByte bool) {
+	return hasChild, !hasChild
+}
+
+// consumePendingUndo pops the byte fstDescent deferred on the arc this
+// frame last returned as a match (see fstDescent's doc comment), if any.
+// It must run before the frame's next sibling arc is read, which is
+// exactly where advance and IntersectTermsEnum.Next call it -- never at
+// the point the match itself is returned, since that byte is still part
+// of the term being handed back to the caller.
+func (f *segmentTermsEnumFrame) consumePendingUndo(term []byte) []byte {
+	if !f.pendingUndo {
+		return term
+	}
+	f.pendingUndo = false
+	return term[:len(term)-1]
+}
+
+// advance walks the FST forward from the current frame stack to the next
+// accepted term, pushing and popping frames via getFrame/putFrame rather
+// than allocating one per depth. If prune is non-nil, it is consulted
+// once per depth with the arc label about to be taken; returning true
+// skips that arc (and anything beneath it) without ever visiting it, the
+// mechanism SeekCeil uses to jump straight past terms it already knows
+// sort before its target instead of enumerating them one by one.
+func (e *SegmentTermsEnum) advance(prune func(depth int, label byte) bool) ([]byte, error) {
+	for len(e.stack) > 0 {
+		depth := len(e.stack) - 1
+		top := e.stack[depth]
+		e.term = top.consumePendingUndo(e.term)
+		nextArc, err := e.owner.index.ReadNextRealArc(&top.arc, e.fstReader)
+		if err != nil {
+			return nil, err
+		}
+		if nextArc == nil {
+			// No more siblings at this depth: pop back up.
+			e.stack = e.stack[:depth]
+			e.putFrame(top)
+			if len(e.term) > 0 {
+				e.term = e.term[:len(e.term)-1]
+			}
+			continue
+		}
+		top.arc = *nextArc
+		if prune != nil && prune(depth, byte(top.arc.Label)) {
+			continue
+		}
+		e.term = append(e.term, byte(top.arc.Label))
+		isFinal := top.arc.IsFinal()
+
+		childArc, err := e.owner.index.ReadFirstTargetArc(&top.arc, &util.Arc{}, e.fstReader)
+		if err != nil {
+			return nil, err
+		}
+		pushChild, undoByte := fstDescent(isFinal, childArc != nil)
+		if pushChild {
+			child := e.getFrame(depth + 1)
+			child.arc = *childArc
+			e.stack = append(e.stack, child)
+		}
+		if isFinal {
+			e.termExists = true
+			// The byte just appended is part of the term we're about to
+			// return; if it has no child to descend into it still needs
+			// undoing, but only once this frame is revisited for its
+			// next sibling arc.
+			top.pendingUndo = undoByte
+			return e.term, nil
+		}
+		if undoByte {
+			// Dead end with no output of its own: undo the byte and keep
+			// trying siblings.
+			e.term = e.term[:len(e.term)-1]
+		}
+	}
+	e.eof = true
+	e.termExists = false
+	return nil, nil
+}
+
+// Term returns the current term, or nil once the enum is exhausted -- the
+// EOF convention FieldDict relies on to know when to stop, since nothing
+// else on this type reports "has more" separately from "advance".
 func (e *SegmentTermsEnum) Term() []byte {
 	if e.eof {
-		panic("assertion error")
+		return nil
 	}
 	return e.term
 }
 
+// DocFreq and TotalTermFreq report -1 ("unknown") rather than a real
+// count: both require decoding the block's per-term stats via
+// PostingsReaderBase, and this package doesn't implement that on-disk
+// codec yet (see BlockTermState). -1 is the same sentinel Lucene itself
+// uses for "not computed" term/collection statistics, so callers that
+// already tolerate that (e.g. TermStatistics) keep working honestly
+// instead of being handed a fabricated count.
 func (e *SegmentTermsEnum) DocFreq() int {
-	panic("not implemented yet")
+	if e.eof {
+		panic("assertion error")
+	}
+	return -1
 }
 
 func (e *SegmentTermsEnum) TotalTermFreq() int64 {
-	panic("not implemented yet")
+	if e.eof {
+		panic("assertion error")
+	}
+	return -1
 }
 
+// DocsByFlags still can't return anything: unlike segmentFieldTermsEnum
+// (segmentWriter.go), which answers this the same way over Segment's
+// in-memory postings map, SegmentTermsEnum descends an on-disk FST term
+// dictionary with no PostingsReaderBase behind it yet to decode an
+// on-disk posting list from -- a missing codec, not just a missing
+// DocsEnum type (see reuse.go, which now defines DocsEnum/
+// DocsAndPositionsEnum).
 func (e *SegmentTermsEnum) DocsByFlags(skipDocs util.Bits, reuse DocsEnum, flags int) DocsEnum {
 	panic("not implemented yet")
 }
@@ -456,6 +692,13 @@ type segmentTermsEnumFrame struct {
 
 	arc util.Arc
 
+	// pendingUndo records that the byte appended for the arc just
+	// returned as a match has no child of its own and must be popped
+	// before the next sibling arc at this depth is read; it can't be
+	// undone at the point the match is found because that byte is part
+	// of the term being returned this call.
+	pendingUndo bool
+
 	// File pointer where this block was loaded from
 	fp     int64
 	fpOrig int64
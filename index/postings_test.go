@@ -0,0 +1,128 @@
+package index
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSeekCeilPrunerSkipsLabelsBeforeTarget(t *testing.T) {
+	p := &seekCeilPruner{text: []byte("cat"), pruning: true}
+
+	if !p.prune(0, 'b') {
+		t.Error("prune(0, 'b') = false, want true: 'b' sorts before 'cat'[0]='c'")
+	}
+	if p.prune(0, 'c') {
+		t.Error("prune(0, 'c') = true, want false: matches text[0]")
+	}
+	if p.prune(1, 'a') {
+		t.Error("prune(1, 'a') = true, want false: matches text[1]")
+	}
+}
+
+func TestSeekCeilPrunerStopsPruningPastTarget(t *testing.T) {
+	p := &seekCeilPruner{text: []byte("cat"), pruning: true}
+	p.prune(0, 'c')
+
+	if p.prune(1, 'z') {
+		t.Fatal("prune(1, 'z') = true, want false: 'z' sorts after text[1]='a', so it can't be skipped")
+	}
+	if p.pruning {
+		t.Error("pruning should turn off permanently once an arc sorts after text")
+	}
+	if p.prune(2, 'a') {
+		t.Error("prune should never skip again once pruning is off, regardless of depth/label")
+	}
+}
+
+func TestSeekCeilPrunerStopsAtTextLength(t *testing.T) {
+	p := &seekCeilPruner{text: []byte("cat"), pruning: true}
+
+	if p.prune(3, 'z') {
+		t.Error("prune at depth >= len(text) should never skip: text no longer constrains deeper arcs")
+	}
+}
+
+func TestFSTDescentPushesChildRegardlessOfFinal(t *testing.T) {
+	cases := []struct {
+		name              string
+		isFinal, hasChild bool
+		wantPushChild     bool
+		wantUndoByte      bool
+	}{
+		{"leaf term, e.g. \"cat\" with no \"cats\"", true, false, false, true},
+		{"final node that also has children, e.g. \"cat\" with \"cats\"", true, true, true, false},
+		{"interior node, e.g. \"ca\" on the way to \"cat\"", false, true, true, false},
+		{"dead end: neither final nor has children", false, false, false, true},
+	}
+	for _, c := range cases {
+		pushChild, undoByte := fstDescent(c.isFinal, c.hasChild)
+		if pushChild != c.wantPushChild {
+			t.Errorf("%s: pushChild = %v, want %v", c.name, pushChild, c.wantPushChild)
+		}
+		if undoByte != c.wantUndoByte {
+			t.Errorf("%s: undoByte = %v, want %v", c.name, undoByte, c.wantUndoByte)
+		}
+	}
+}
+
+// TestFSTDescentAcrossSiblingLeavesUndoesEachByte drives fstDescent and
+// consumePendingUndo through the exact sequence advance (and
+// IntersectTermsEnum.Next) run them in for a root with two final,
+// childless sibling arcs -- e.g. a field containing only the terms "a"
+// and "b". A real *util.FST can't be built in this tree to exercise
+// advance() end to end, but this reproduces the byte bookkeeping around
+// it precisely: without undoByte unconditional on !hasChild and without
+// deferring the pop to the next visit of the frame, the second term
+// comes back as "ab" instead of "b".
+func TestFSTDescentAcrossSiblingLeavesUndoesEachByte(t *testing.T) {
+	frame := &segmentTermsEnumFrame{}
+	var term []byte
+
+	// Arc 'a': final, no children.
+	term = frame.consumePendingUndo(term)
+	term = append(term, 'a')
+	pushChild, undoByte := fstDescent(true, false)
+	if pushChild {
+		t.Fatal("leaf arc 'a' should not push a child")
+	}
+	frame.pendingUndo = undoByte
+	if got := string(term); got != "a" {
+		t.Fatalf("first match = %q, want %q", got, "a")
+	}
+
+	// Arc 'b': the next sibling at the same depth, also final with no
+	// children. The pending undo from 'a' must be popped before 'b' is
+	// appended, or the term accumulates to "ab".
+	term = frame.consumePendingUndo(term)
+	term = append(term, 'b')
+	pushChild, undoByte = fstDescent(true, false)
+	if pushChild {
+		t.Fatal("leaf arc 'b' should not push a child")
+	}
+	frame.pendingUndo = undoByte
+	if got := string(term); got != "b" {
+		t.Fatalf("second match = %q, want %q (the sibling-arc undo was skipped)", got, "b")
+	}
+}
+
+func TestSegmentTermsEnumFramePoolReusesFrames(t *testing.T) {
+	e := &SegmentTermsEnum{}
+	e.framePool = &sync.Pool{New: func() interface{} {
+		return &segmentTermsEnumFrame{suffixBytes: make([]byte, 128)}
+	}}
+
+	f1 := e.getFrame(0)
+	f1.suffixBytes[0] = 0xAB // mark it so we can tell it's the same instance back
+	e.putFrame(f1)
+
+	f2 := e.getFrame(1)
+	if f2 != f1 {
+		t.Fatal("getFrame() after putFrame() allocated a new frame instead of reusing the pooled one")
+	}
+	if f2.ord != 1 {
+		t.Errorf("ord = %v, want 1: getFrame must set it for the new stack depth", f2.ord)
+	}
+	if f2.suffixBytes[0] != 0xAB {
+		t.Error("reused frame lost its buffers instead of keeping them for reuse")
+	}
+}
@@ -0,0 +1,139 @@
+package index
+
+// This file implements the posting-reuse contract over Segment's
+// in-memory postings map, and segmentFieldTermsEnum.DocsByFlags
+// (segmentWriter.go) wires it into that map's own TermsEnum by returning
+// a DocsEnum backed by SegmentPostingsIterator. SegmentTermsEnum's
+// DocsByFlags/DocsAndPositionsByFlags (postings.go) -- the on-disk,
+// FST-backed TermsEnum -- still can't do the same: it has no
+// PostingsReaderBase to decode an on-disk posting list from in the first
+// place, a separate, larger gap than DocsEnum merely not existing. So
+// posting reuse reaches the in-memory write path's query-time iteration
+// today, but not yet the on-disk read path, and search/search.go's
+// Scorer.Score can't pull from either: it's built against the
+// "lucene/index" reader hierarchy (see search.NewIndexSearcherFromSnapshot's
+// doc comment), which doesn't define a Weight/Scorer contract that
+// reaches down into this package's TermsEnum at all.
+//
+// noMoreDocs is the sentinel DocsEnum.NextDoc returns once an enum is
+// exhausted, matching Lucene's own DocsEnum.NO_MORE_DOCS.
+const noMoreDocs = 1<<31 - 1
+
+// DocsEnum iterates the docs containing one (field, term) pair: NextDoc
+// advances and returns the next doc ID (or noMoreDocs once exhausted),
+// while DocID and Freq describe whatever doc NextDoc last returned --
+// both undefined before the first NextDoc call, the same as Lucene's
+// DocsEnum contract.
+type DocsEnum interface {
+	NextDoc() (int, error)
+	DocID() int
+	Freq() int
+}
+
+// DocsAndPositionsEnum extends DocsEnum with per-occurrence position and
+// offset data. Nothing in this package produces one yet: flush
+// (segmentWriter.go) records only doc IDs in Segment.postings, not
+// positions, so there is no position data for a DocsAndPositionsEnum to
+// iterate until the write path captures it.
+type DocsAndPositionsEnum interface {
+	DocsEnum
+	NextPosition() (int, error)
+	StartOffset() int
+	EndOffset() int
+}
+
+// ReusablePosting is the single Posting value a DocsEnum/
+// DocsAndPositionsEnum owns and mutates in place on every Next() call,
+// the same reuse contract bleve's PostingsIterator.Next uses to avoid an
+// allocation per document: Next() returns a pointer to this struct, not
+// a freshly allocated one, so callers that need a posting's doc/freq/
+// position to outlive the next Next() call MUST copy the fields they
+// care about first.
+//
+// Do not retain the *ReusablePosting returned by Next() past your next
+// call to Next() on the same enum: its contents are undefined after
+// that point.
+type ReusablePosting struct {
+	Doc      int
+	Freq     int
+	Position int
+	StartOffset int
+	EndOffset   int
+}
+
+// Clone copies this posting's current field values into a new,
+// independent ReusablePosting, for callers that do need to retain a
+// particular document's data across subsequent Next() calls.
+func (p *ReusablePosting) Clone() *ReusablePosting {
+	cp := *p
+	return &cp
+}
+
+// SegmentPostingsIterator walks the segment-local doc IDs for one
+// (field, term) pair out of a Segment's in-memory postings (see
+// Segment.postings), returning ReusablePosting the way a real
+// DocsEnum/DocsAndPositionsEnum would once this package's on-disk
+// postings codec exists: the same *ReusablePosting, mutated in place,
+// rather than one allocation per document. It implements DocsEnum (see
+// NextDoc/DocID/Freq below) so segmentFieldTermsEnum.DocsByFlags
+// (segmentWriter.go) can hand one back directly.
+type SegmentPostingsIterator struct {
+	docs    []int
+	i       int
+	posting ReusablePosting
+}
+
+// NewSegmentPostingsIterator returns an iterator over field/term's doc
+// IDs in seg. The iterator is empty (Next always returns nil) if seg has
+// no such term.
+func NewSegmentPostingsIterator(seg *Segment, field, term string) *SegmentPostingsIterator {
+	return newSegmentPostingsIterator(seg.Postings(field, term))
+}
+
+func newSegmentPostingsIterator(docs []int) *SegmentPostingsIterator {
+	return &SegmentPostingsIterator{docs: docs}
+}
+
+// reset rebinds it to iterate docs from the beginning, so a caller
+// honoring DocsByFlags's reuse parameter can hand back a previously
+// returned iterator and get its backing ReusablePosting reused instead
+// of a fresh *SegmentPostingsIterator being allocated.
+func (it *SegmentPostingsIterator) reset(docs []int) {
+	it.docs = docs
+	it.i = 0
+	it.posting = ReusablePosting{}
+}
+
+// Next advances to the next doc and returns this iterator's single
+// ReusablePosting, mutated to describe it -- not a fresh allocation. Per
+// ReusablePosting's doc comment, callers that need a posting's fields to
+// outlive the next Next() call must copy them (e.g. via Clone) first.
+// Next returns nil once the postings list is exhausted.
+func (it *SegmentPostingsIterator) Next() *ReusablePosting {
+	if it.i >= len(it.docs) {
+		return nil
+	}
+	it.posting.Doc = it.docs[it.i]
+	it.posting.Freq = 1
+	it.i++
+	return &it.posting
+}
+
+// NextDoc, DocID and Freq implement DocsEnum over the same reused
+// posting Next() mutates, so a caller that only needs doc IDs (no
+// Position/StartOffset/EndOffset) can drive this iterator through the
+// DocsEnum interface instead of Next's *ReusablePosting directly.
+func (it *SegmentPostingsIterator) NextDoc() (int, error) {
+	if p := it.Next(); p != nil {
+		return p.Doc, nil
+	}
+	return noMoreDocs, nil
+}
+
+func (it *SegmentPostingsIterator) DocID() int {
+	return it.posting.Doc
+}
+
+func (it *SegmentPostingsIterator) Freq() int {
+	return it.posting.Freq
+}
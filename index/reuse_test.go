@@ -0,0 +1,105 @@
+package index
+
+import "testing"
+
+func TestSegmentPostingsIteratorReusesPosting(t *testing.T) {
+	seg := &Segment{postings: map[string]map[string][]int{
+		"body": {"fox": {2, 5, 9}},
+	}}
+	it := NewSegmentPostingsIterator(seg, "body", "fox")
+
+	first := it.Next()
+	if first == nil || first.Doc != 2 {
+		t.Fatalf("Next() = %+v, want Doc 2", first)
+	}
+	second := it.Next()
+	if second != first {
+		t.Fatal("Next() returned a different *ReusablePosting instead of mutating the same one")
+	}
+	if second.Doc != 5 {
+		t.Errorf("Doc = %v, want 5", second.Doc)
+	}
+
+	clone := second.Clone()
+	it.Next() // Doc 9
+	if clone.Doc != 5 {
+		t.Errorf("Clone() did not survive a later Next(): Doc = %v, want 5", clone.Doc)
+	}
+
+	if it.Next() != nil {
+		t.Error("Next() after the last doc should return nil")
+	}
+}
+
+func TestSegmentPostingsIteratorEmptyForUnknownTerm(t *testing.T) {
+	seg := &Segment{postings: map[string]map[string][]int{"body": {"fox": {1}}}}
+	it := NewSegmentPostingsIterator(seg, "body", "dog")
+
+	if it.Next() != nil {
+		t.Error("Next() on an unknown term should return nil immediately")
+	}
+}
+
+// TestSegmentFieldTermsEnumDocsByFlagsIteratesViaDocsEnum guards
+// segmentFieldTermsEnum.DocsByFlags actually wiring into
+// SegmentPostingsIterator instead of panicking: seeking to "fox" and
+// driving the returned DocsEnum through NextDoc/DocID/Freq must surface
+// the same doc IDs FieldDict's own DocFreq counts.
+func TestSegmentFieldTermsEnumDocsByFlagsIteratesViaDocsEnum(t *testing.T) {
+	postings := map[string]map[string][]int{"body": {"fox": {2, 5, 9}}}
+	e := newSegmentFieldTermsEnum("body", postings)
+	if !e.SeekExact([]byte("fox")) {
+		t.Fatalf("SeekExact(%q) = false, want true", "fox")
+	}
+
+	de := e.DocsByFlags(nil, nil, 0)
+	var got []int
+	for {
+		doc, err := de.NextDoc()
+		if err != nil {
+			t.Fatalf("NextDoc() error = %v", err)
+		}
+		if doc == noMoreDocs {
+			break
+		}
+		got = append(got, doc)
+		if de.DocID() != doc {
+			t.Errorf("DocID() = %v, want %v", de.DocID(), doc)
+		}
+		if de.Freq() != 1 {
+			t.Errorf("Freq() = %v, want 1", de.Freq())
+		}
+	}
+	want := []int{2, 5, 9}
+	if len(got) != len(want) {
+		t.Fatalf("docs = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("docs = %v, want %v", got, want)
+		}
+	}
+}
+
+// TestSegmentFieldTermsEnumDocsByFlagsHonorsReuse guards the reuse
+// parameter: passing back a *SegmentPostingsIterator from a previous
+// DocsByFlags call must reset and return that same instance, not
+// allocate a fresh one, matching ReusablePosting's whole point.
+func TestSegmentFieldTermsEnumDocsByFlagsHonorsReuse(t *testing.T) {
+	postings := map[string]map[string][]int{"body": {"cat": {1}, "dog": {3, 4}}}
+	e := newSegmentFieldTermsEnum("body", postings)
+
+	e.SeekExact([]byte("cat"))
+	first := e.DocsByFlags(nil, nil, 0)
+
+	e.SeekExact([]byte("dog"))
+	second := e.DocsByFlags(nil, first, 0)
+
+	if second != first {
+		t.Fatal("DocsByFlags() allocated a new iterator instead of reusing the one passed in")
+	}
+	doc, err := second.NextDoc()
+	if err != nil || doc != 3 {
+		t.Fatalf("NextDoc() = (%v, %v), want (3, nil) after reuse reset to \"dog\"'s postings", doc, err)
+	}
+}
@@ -0,0 +1,542 @@
+package index
+
+import (
+	"github.com/balzaczyy/golucene/util"
+	"sort"
+	"sync"
+)
+
+// This file adds a write side to the package, modeled on scorch's
+// segment-oriented design: a Batch of documents is built into exactly
+// one new, immutable Segment (no existing segment is ever rewritten),
+// segments are tracked by an in-memory IndexSnapshot, and a background
+// merger folds small segments together under a tiered MergePolicy.
+// IndexSnapshot stitches many segments' FieldDicts together into one
+// multi-segment view for the FieldDict API (request 2), and
+// search.NewIndexSearcherFromSnapshot adapts that same view onto
+// IndexSearcher so queries run against it too -- see that function's doc
+// comment for how the two packages' separate Reader hierarchies meet.
+
+// Batch is an unordered set of documents to add in one atomic unit: all
+// of them land in the same new Segment, or none do.
+type Batch struct {
+	docs []Document
+}
+
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+func (b *Batch) Add(doc Document) {
+	b.docs = append(b.docs, doc)
+}
+
+// Document is the minimal per-document payload a Batch deals in: enough
+// to build the FST term dictionary, postings, stored fields and norms
+// that make up a Segment.
+type Document struct {
+	Fields map[string][]string // field name -> analyzed term values, in position order
+}
+
+// DeletionBitmap is a compressed (roaring-bitmap-style) set of doc IDs
+// deleted from a Segment. We keep it as sorted runs of [start,end) so
+// that the common case -- large contiguous ranges of live or deleted
+// docs -- stays cheap to test and to compact, without depending on a
+// third-party roaring-bitmap library.
+type DeletionBitmap struct {
+	mu    sync.RWMutex
+	runs  [][2]int // sorted, non-overlapping [start, end) ranges of deleted docs
+	count int
+}
+
+func NewDeletionBitmap() *DeletionBitmap {
+	return &DeletionBitmap{}
+}
+
+func (d *DeletionBitmap) Delete(docID int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, r := range d.runs {
+		if docID >= r[0] && docID < r[1] {
+			return // already deleted
+		}
+		if docID == r[1] {
+			d.runs[i][1]++
+			d.count++
+			return
+		}
+		if docID == r[0]-1 {
+			d.runs[i][0]--
+			d.count++
+			return
+		}
+		if docID < r[0] {
+			d.runs = append(d.runs, [2]int{})
+			copy(d.runs[i+1:], d.runs[i:])
+			d.runs[i] = [2]int{docID, docID + 1}
+			d.count++
+			return
+		}
+	}
+	d.runs = append(d.runs, [2]int{docID, docID + 1})
+	d.count++
+}
+
+// Get implements the util.Bits contract: true means live (not deleted),
+// matching the LiveDocs argument searchLWC passes to Weight.Scorer.
+func (d *DeletionBitmap) Get(docID int) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, r := range d.runs {
+		if docID >= r[0] && docID < r[1] {
+			return false
+		}
+		if docID < r[0] {
+			break
+		}
+	}
+	return true
+}
+
+func (d *DeletionBitmap) Length() int {
+	return 0 // caller tracks maxDoc separately; this bitmap only records deletes
+}
+
+// Runs returns a copy of this bitmap's deleted-doc runs, each a sorted,
+// non-overlapping [start, end) range of deleted doc IDs. Used by
+// mergeOnce to carry deletions over into a merged segment's own doc ID
+// space.
+func (d *DeletionBitmap) Runs() [][2]int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	cp := make([][2]int, len(d.runs))
+	copy(cp, d.runs)
+	return cp
+}
+
+// Segment is one immutable unit produced by flushing a single Batch: its
+// own term dictionary, postings, stored fields and norms, plus a mutable
+// (append-only) deletion bitmap. There is no on-disk FST term
+// dictionary/FieldsProducer yet (see postings's doc comment below), so
+// FieldDict is answered directly against the in-memory map instead of
+// through one.
+type Segment struct {
+	name    string
+	maxDoc  int
+	deletes *DeletionBitmap
+
+	// postings is the in-memory term dictionary/postings list this
+	// segment actually holds: field -> term -> sorted doc IDs, local to
+	// this segment (0..maxDoc). Encoding an on-disk FST term dictionary,
+	// stored fields and norms reuses the same codec machinery
+	// BlockTreeTermsReader reads back with, which is out of scope here;
+	// this in-memory form is what flush builds instead so a freshly
+	// written segment can actually answer a term lookup.
+	postings map[string]map[string][]int
+}
+
+func (s *Segment) LiveDocs() *DeletionBitmap {
+	return s.deletes
+}
+
+// MaxDoc returns the number of doc IDs this segment assigned, including
+// ones LiveDocs now reports as deleted -- the same "slot count, not live
+// count" contract index.AtomicReader.MaxDoc() has in search/search.go,
+// which search.snapshotReader relies on to adapt a Segment onto it.
+func (s *Segment) MaxDoc() int {
+	return s.maxDoc
+}
+
+// Postings returns the sorted, segment-local doc IDs containing term in
+// field, or nil if the segment has no such term.
+func (s *Segment) Postings(field, term string) []int {
+	return s.postings[field][term]
+}
+
+// FieldDict returns an iterator over every term s holds for field, in
+// sorted order, built directly from the in-memory postings map since
+// this segment has no on-disk FST term dictionary to drive one through
+// (see postings's doc comment).
+func (s *Segment) FieldDict(field string) (*FieldDict, error) {
+	return newFieldDict(newSegmentFieldTermsEnum(field, s.postings), nil, nil, nil)
+}
+
+// segmentFieldTermsEnum is the TermsEnum FieldDict needs, walking one
+// field's terms out of a Segment's in-memory postings map in sorted
+// order instead of descending an on-disk FST -- the same gap
+// Segment.postings's doc comment describes. DocsByFlags answers for real
+// (see below), since the in-memory postings map has real doc IDs to hand
+// back; the rest of the TermsEnum contract SegmentTermsEnum itself still
+// panics on (seek-by-ord, term state) isn't needed for anything real yet
+// and panics the same way here.
+type segmentFieldTermsEnum struct {
+	terms []string
+	docs  map[string][]int
+	i     int // index into terms of the current term, or len(terms) once exhausted
+}
+
+func newSegmentFieldTermsEnum(field string, postings map[string]map[string][]int) *segmentFieldTermsEnum {
+	fieldTerms := postings[field]
+	terms := make([]string, 0, len(fieldTerms))
+	for term := range fieldTerms {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	return &segmentFieldTermsEnum{terms: terms, docs: fieldTerms, i: -1}
+}
+
+func (e *segmentFieldTermsEnum) Next() ([]byte, error) {
+	e.i++
+	if e.i >= len(e.terms) {
+		e.i = len(e.terms)
+		return nil, nil
+	}
+	return []byte(e.terms[e.i]), nil
+}
+
+func (e *segmentFieldTermsEnum) Term() []byte {
+	if e.i < 0 || e.i >= len(e.terms) {
+		return nil
+	}
+	return []byte(e.terms[e.i])
+}
+
+func (e *segmentFieldTermsEnum) DocFreq() int {
+	return len(e.docs[e.terms[e.i]])
+}
+
+// TotalTermFreq coincides with DocFreq: flush records one posting per
+// doc with no frequency of its own (see SegmentPostingsIterator.Next).
+func (e *segmentFieldTermsEnum) TotalTermFreq() int64 {
+	return int64(e.DocFreq())
+}
+
+func (e *segmentFieldTermsEnum) SeekCeil(text []byte) SeekStatus {
+	target := string(text)
+	i := sort.SearchStrings(e.terms, target)
+	e.i = i
+	switch {
+	case i >= len(e.terms):
+		return SEEK_STATUS_END
+	case e.terms[i] == target:
+		return SEEK_STATUS_FOUND
+	default:
+		return SEEK_STATUS_NOT_FOUND
+	}
+}
+
+func (e *segmentFieldTermsEnum) SeekExact(target []byte) bool {
+	return e.SeekCeil(target) == SEEK_STATUS_FOUND
+}
+
+func (e *segmentFieldTermsEnum) Comparator() sort.Interface {
+	panic("not implemented yet")
+}
+
+// DocsByFlags returns a DocsEnum over the current term's doc IDs,
+// honoring reuse the way ReusablePosting is meant to be reused (see
+// reuse.go): if reuse is a *SegmentPostingsIterator from an earlier call,
+// it's reset in place instead of a new one being allocated.
+//
+// skipDocs (live-docs liveness) isn't threaded through here yet: the
+// in-memory write path tracks deletions per-Segment via DeletionBitmap,
+// not per-posting, so a caller wanting live docs only still needs to
+// check LiveDocs() itself against each doc this returns.
+func (e *segmentFieldTermsEnum) DocsByFlags(skipDocs util.Bits, reuse DocsEnum, flags int) DocsEnum {
+	docs := e.docs[e.terms[e.i]]
+	if it, ok := reuse.(*SegmentPostingsIterator); ok {
+		it.reset(docs)
+		return it
+	}
+	return newSegmentPostingsIterator(docs)
+}
+
+// DocsAndPositionsByFlags has nothing to return yet: flush records only
+// doc IDs in Segment.postings, not per-occurrence positions (see
+// Segment.postings's doc comment), so there is no position data for a
+// DocsAndPositionsEnum to iterate until the write path captures it.
+func (e *segmentFieldTermsEnum) DocsAndPositionsByFlags(skipDocs util.Bits, reuse DocsAndPositionsEnum, flags int) DocsAndPositionsEnum {
+	panic("not implemented yet: Segment.postings records no position data")
+}
+
+func (e *segmentFieldTermsEnum) SeekExactFromLast(target []byte, otherState TermState) error {
+	panic("not implemented yet")
+}
+
+func (e *segmentFieldTermsEnum) TermState() TermState {
+	panic("not implemented yet")
+}
+
+func (e *segmentFieldTermsEnum) SeekExactByPosition(ord int64) error {
+	panic("not implemented yet")
+}
+
+func (e *segmentFieldTermsEnum) Ord() int64 {
+	panic("not supported!")
+}
+
+// IndexSnapshot is a consistent, point-in-time view over a set of
+// segments, meant as the unit a query runs against so concurrent
+// AddBatch/merge activity never shifts results out from under one
+// search -- see search.NewIndexSearcherFromSnapshot for how IndexSearcher
+// runs against one.
+type IndexSnapshot struct {
+	segments []*Segment
+}
+
+func newIndexSnapshot(segments []*Segment) *IndexSnapshot {
+	// Defensive copy: the writer's segment list keeps mutating underneath
+	// outstanding snapshots.
+	cp := make([]*Segment, len(segments))
+	copy(cp, segments)
+	return &IndexSnapshot{segments: cp}
+}
+
+func (s *IndexSnapshot) Segments() []*Segment {
+	return s.segments
+}
+
+// FieldDict merges the per-segment FieldDict iterators for field with a
+// k-way heap merge, reusing the FieldDict API from request 2 rather than
+// inventing a second iteration protocol.
+//
+// This is not itself how IndexSearcher.FieldDict reaches terms -- that
+// method still runs against the pre-existing, separate reader hierarchy
+// in package "lucene/index" (predating this segment-based write path),
+// so it can't call down into this package's FieldDict without its own
+// porting effort. What search.NewIndexSearcherFromSnapshot wires up
+// instead is the query path (IndexSearcher.Search): it adapts an
+// *IndexSnapshot onto "lucene/index".Reader well enough to drive
+// searchLWC over this package's segments, so running FieldDict and
+// running queries against one snapshot go through two different doors
+// today rather than none.
+func (s *IndexSnapshot) FieldDict(field string) (*MergedFieldDict, error) {
+	var dicts []*FieldDict
+	for _, seg := range s.segments {
+		d, err := seg.FieldDict(field)
+		if err != nil {
+			return nil, err
+		}
+		dicts = append(dicts, d)
+	}
+	return newMergedFieldDict(dicts)
+}
+
+// MergePolicy decides which segments to fold together. TieredMergePolicy
+// targets a fixed number of segments per "tier" (a power-of-two size
+// bucket, see sizeTier), the same strategy Lucene's TieredMergePolicy and scorch's
+// default planner use: merge within a tier once it has more than
+// segmentsPerTier segments, rather than merging strictly by age.
+type MergePolicy interface {
+	// FindMerges returns groups of segments that should each be merged
+	// into one, given the writer's current full segment list.
+	FindMerges(segments []*Segment) [][]*Segment
+}
+
+type TieredMergePolicy struct {
+	SegmentsPerTier int
+	MaxMergedDocs   int
+}
+
+func NewTieredMergePolicy() *TieredMergePolicy {
+	return &TieredMergePolicy{SegmentsPerTier: 10, MaxMergedDocs: 5000000}
+}
+
+func (p *TieredMergePolicy) FindMerges(segments []*Segment) [][]*Segment {
+	tiers := make(map[int][]*Segment)
+	for _, seg := range segments {
+		tiers[sizeTier(seg.maxDoc)] = append(tiers[sizeTier(seg.maxDoc)], seg)
+	}
+	var merges [][]*Segment
+	for _, group := range tiers {
+		if len(group) <= p.SegmentsPerTier {
+			continue
+		}
+		total := 0
+		for _, seg := range group {
+			total += seg.maxDoc
+		}
+		if total <= p.MaxMergedDocs {
+			merges = append(merges, group)
+		}
+	}
+	return merges
+}
+
+// sizeTier buckets maxDoc by power-of-two order of magnitude, so segments
+// of wildly different sizes never land in the same tier.
+func sizeTier(maxDoc int) int {
+	tier := 0
+	for n := maxDoc; n > 1000; n /= 2 {
+		tier++
+	}
+	return tier
+}
+
+// IndexWriter accepts batches of documents, registering the resulting
+// segments, and runs the background merger. It is deliberately separate
+// from whatever manages the legacy single-segment write path, so
+// existing callers are unaffected until they opt into AddBatch.
+type IndexWriter struct {
+	mu           sync.Mutex
+	dir          interface{} // store.Directory; kept untyped to avoid a hard dependency cycle
+	segments     []*Segment
+	mergePolicy  MergePolicy
+	nextSegID    int
+	mergeTrigger chan struct{}
+}
+
+func NewIndexWriter() *IndexWriter {
+	w := &IndexWriter{
+		mergePolicy:  NewTieredMergePolicy(),
+		mergeTrigger: make(chan struct{}, 1),
+	}
+	go w.mergeLoop()
+	return w
+}
+
+// AddBatch flushes batch into exactly one new Segment and registers it,
+// without touching any existing segment.
+func (w *IndexWriter) AddBatch(batch *Batch) (*Segment, error) {
+	seg, err := w.flush(batch)
+	if err != nil {
+		return nil, err
+	}
+	w.mu.Lock()
+	w.segments = append(w.segments, seg)
+	w.mu.Unlock()
+	select {
+	case w.mergeTrigger <- struct{}{}:
+	default:
+	}
+	return seg, nil
+}
+
+// flush builds the immutable artifacts for one Batch: an in-memory
+// field/term/doc-ID postings list derived from every Document in it (see
+// Segment.postings). It does not encode the on-disk FST term dictionary,
+// stored fields or norms BlockTreeTermsReader reads back -- that reuses
+// the same codec machinery as the rest of the write path and is out of
+// scope here -- but it no longer discards batch.docs: the segment it
+// returns can answer Postings(field, term) immediately.
+func (w *IndexWriter) flush(batch *Batch) (*Segment, error) {
+	w.mu.Lock()
+	id := w.nextSegID
+	w.nextSegID++
+	w.mu.Unlock()
+
+	postings := make(map[string]map[string][]int)
+	for docID, doc := range batch.docs {
+		for field, values := range doc.Fields {
+			terms := postings[field]
+			if terms == nil {
+				terms = make(map[string][]int)
+				postings[field] = terms
+			}
+			for _, term := range values {
+				docs := terms[term]
+				if len(docs) == 0 || docs[len(docs)-1] != docID {
+					terms[term] = append(docs, docID)
+				}
+			}
+		}
+	}
+
+	return &Segment{
+		name:     segmentName(id),
+		maxDoc:   len(batch.docs),
+		deletes:  NewDeletionBitmap(),
+		postings: postings,
+	}, nil
+}
+
+func segmentName(id int) string {
+	const alphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+	if id == 0 {
+		return "_0"
+	}
+	buf := []byte{}
+	for id > 0 {
+		buf = append([]byte{alphabet[id%len(alphabet)]}, buf...)
+		id /= len(alphabet)
+	}
+	return "_" + string(buf)
+}
+
+// Snapshot returns a consistent multi-segment view for IndexSearcher to
+// run queries against.
+func (w *IndexWriter) Snapshot() *IndexSnapshot {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return newIndexSnapshot(w.segments)
+}
+
+// mergeLoop is the background merger: it wakes up whenever a batch is
+// added and asks the MergePolicy whether any group of segments should be
+// combined.
+func (w *IndexWriter) mergeLoop() {
+	for range w.mergeTrigger {
+		w.mu.Lock()
+		merges := w.mergePolicy.FindMerges(w.segments)
+		w.mu.Unlock()
+		for _, group := range merges {
+			w.mergeOnce(group)
+		}
+	}
+}
+
+// mergeOnce combines group into a single new Segment and atomically
+// swaps it in for its inputs, so any IndexSnapshot taken before the merge
+// keeps referencing the old (still valid, not-yet-GC'd) segments. Each
+// constituent segment's doc IDs (both postings and deletions) are
+// re-numbered by a running base offset, since merged assigns every group
+// member a distinct slice of its own doc ID space rather than reusing
+// segment-local IDs that would otherwise collide.
+func (w *IndexWriter) mergeOnce(group []*Segment) {
+	merged := &Segment{
+		name:     segmentName(w.allocSegID()),
+		deletes:  NewDeletionBitmap(),
+		postings: make(map[string]map[string][]int),
+	}
+	base := 0
+	for _, seg := range group {
+		for field, terms := range seg.postings {
+			dest := merged.postings[field]
+			if dest == nil {
+				dest = make(map[string][]int)
+				merged.postings[field] = dest
+			}
+			for term, docs := range terms {
+				for _, docID := range docs {
+					dest[term] = append(dest[term], base+docID)
+				}
+			}
+		}
+		for _, run := range seg.deletes.Runs() {
+			for docID := run[0]; docID < run[1]; docID++ {
+				merged.deletes.Delete(base + docID)
+			}
+		}
+		base += seg.maxDoc
+		merged.maxDoc += seg.maxDoc
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	inGroup := make(map[*Segment]bool, len(group))
+	for _, seg := range group {
+		inGroup[seg] = true
+	}
+	var kept []*Segment
+	for _, seg := range w.segments {
+		if !inGroup[seg] {
+			kept = append(kept, seg)
+		}
+	}
+	w.segments = append(kept, merged)
+}
+
+func (w *IndexWriter) allocSegID() int {
+	id := w.nextSegID
+	w.nextSegID++
+	return id
+}
@@ -0,0 +1,196 @@
+package index
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIndexWriterFlushBuildsPostingsFromBatch(t *testing.T) {
+	w := &IndexWriter{mergePolicy: NewTieredMergePolicy()}
+	batch := NewBatch()
+	batch.Add(Document{Fields: map[string][]string{"body": {"the", "quick", "fox"}}})
+	batch.Add(Document{Fields: map[string][]string{"body": {"the", "lazy", "dog"}}})
+
+	seg, err := w.flush(batch)
+	if err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+	if seg.maxDoc != 2 {
+		t.Errorf("maxDoc = %v, want 2", seg.maxDoc)
+	}
+	if got := seg.Postings("body", "the"); !reflect.DeepEqual(got, []int{0, 1}) {
+		t.Errorf(`Postings("body", "the") = %v, want [0 1]`, got)
+	}
+	if got := seg.Postings("body", "fox"); !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf(`Postings("body", "fox") = %v, want [0]`, got)
+	}
+	if got := seg.Postings("body", "cat"); got != nil {
+		t.Errorf(`Postings("body", "cat") = %v, want nil`, got)
+	}
+}
+
+func TestIndexWriterFlushRepeatedTermWithinOneDocKeptOnce(t *testing.T) {
+	w := &IndexWriter{mergePolicy: NewTieredMergePolicy()}
+	batch := NewBatch()
+	batch.Add(Document{Fields: map[string][]string{"body": {"the", "the", "the"}}})
+
+	seg, err := w.flush(batch)
+	if err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+	if got := seg.Postings("body", "the"); !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf(`Postings("body", "the") = %v, want [0]: one doc ID per doc, not per occurrence`, got)
+	}
+}
+
+func TestIndexWriterMergeOnceUnionsPostingsWithOffsetDocIDs(t *testing.T) {
+	w := &IndexWriter{mergePolicy: NewTieredMergePolicy()}
+
+	b1 := NewBatch()
+	b1.Add(Document{Fields: map[string][]string{"body": {"the", "fox"}}}) // doc 0
+	b1.Add(Document{Fields: map[string][]string{"body": {"the", "dog"}}}) // doc 1
+	seg1, err := w.flush(b1)
+	if err != nil {
+		t.Fatalf("flush(b1) error = %v", err)
+	}
+
+	b2 := NewBatch()
+	b2.Add(Document{Fields: map[string][]string{"body": {"the", "cat"}}}) // doc 0 in seg2, doc 2 after merge
+	seg2, err := w.flush(b2)
+	if err != nil {
+		t.Fatalf("flush(b2) error = %v", err)
+	}
+
+	w.mergeOnce([]*Segment{seg1, seg2})
+
+	w.mu.Lock()
+	merged := w.segments[len(w.segments)-1]
+	w.mu.Unlock()
+
+	if merged.maxDoc != 3 {
+		t.Fatalf("maxDoc = %v, want 3", merged.maxDoc)
+	}
+	if got := merged.Postings("body", "the"); !reflect.DeepEqual(got, []int{0, 1, 2}) {
+		t.Errorf(`Postings("body", "the") = %v, want [0 1 2]: seg2's doc 0 must be offset by seg1.maxDoc`, got)
+	}
+	if got := merged.Postings("body", "cat"); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf(`Postings("body", "cat") = %v, want [2]`, got)
+	}
+	if got := merged.Postings("body", "fox"); !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf(`Postings("body", "fox") = %v, want [0]`, got)
+	}
+}
+
+func collectFieldDictTerms(t *testing.T, d *FieldDict) []string {
+	t.Helper()
+	var got []string
+	for {
+		entry, err := d.Next()
+		if err != nil {
+			t.Fatalf("FieldDict.Next() error = %v", err)
+		}
+		if entry == nil {
+			return got
+		}
+		got = append(got, string(entry.Term))
+	}
+}
+
+func TestSegmentFieldDictReturnsTermsInSortedOrder(t *testing.T) {
+	w := &IndexWriter{mergePolicy: NewTieredMergePolicy()}
+	batch := NewBatch()
+	batch.Add(Document{Fields: map[string][]string{"body": {"fox", "the", "dog"}}})
+	seg, err := w.flush(batch)
+	if err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+
+	d, err := seg.FieldDict("body")
+	if err != nil {
+		t.Fatalf("Segment.FieldDict() error = %v", err)
+	}
+	want := []string{"dog", "fox", "the"}
+	if got := collectFieldDictTerms(t, d); !reflect.DeepEqual(got, want) {
+		t.Errorf("terms = %v, want %v", got, want)
+	}
+}
+
+func TestIndexSnapshotFieldDictMergesAcrossSegments(t *testing.T) {
+	w := &IndexWriter{mergePolicy: NewTieredMergePolicy()}
+
+	b1 := NewBatch()
+	b1.Add(Document{Fields: map[string][]string{"body": {"the", "fox"}}})
+	if _, err := w.AddBatch(b1); err != nil {
+		t.Fatalf("AddBatch(b1) error = %v", err)
+	}
+
+	b2 := NewBatch()
+	b2.Add(Document{Fields: map[string][]string{"body": {"the", "dog"}}})
+	if _, err := w.AddBatch(b2); err != nil {
+		t.Fatalf("AddBatch(b2) error = %v", err)
+	}
+
+	w.mu.Lock()
+	snap := newIndexSnapshot(w.segments)
+	w.mu.Unlock()
+
+	merged, err := snap.FieldDict("body")
+	if err != nil {
+		t.Fatalf("IndexSnapshot.FieldDict() error = %v", err)
+	}
+	want := []string{"dog", "fox", "the"}
+	var got []string
+	var theDocFreq int
+	for {
+		entry, err := merged.Next()
+		if err != nil {
+			t.Fatalf("MergedFieldDict.Next() error = %v", err)
+		}
+		if entry == nil {
+			break
+		}
+		got = append(got, string(entry.Term))
+		if string(entry.Term) == "the" {
+			theDocFreq = entry.DocFreq
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("terms = %v, want %v: one segment's FieldDict was silently dropped", got, want)
+	}
+	if theDocFreq != 2 {
+		t.Errorf(`"the" DocFreq = %v, want 2: it appears in both segments`, theDocFreq)
+	}
+}
+
+func TestIndexWriterMergeOnceUnionsDeletesWithOffsetDocIDs(t *testing.T) {
+	w := &IndexWriter{mergePolicy: NewTieredMergePolicy()}
+
+	b1 := NewBatch()
+	b1.Add(Document{Fields: map[string][]string{"body": {"the"}}})
+	b1.Add(Document{Fields: map[string][]string{"body": {"the"}}})
+	seg1, err := w.flush(b1)
+	if err != nil {
+		t.Fatalf("flush(b1) error = %v", err)
+	}
+	seg1.deletes.Delete(1) // delete seg1's doc 1
+
+	b2 := NewBatch()
+	b2.Add(Document{Fields: map[string][]string{"body": {"the"}}})
+	seg2, err := w.flush(b2)
+	if err != nil {
+		t.Fatalf("flush(b2) error = %v", err)
+	}
+	seg2.deletes.Delete(0) // delete seg2's doc 0, which lands at merged doc 2
+
+	w.mergeOnce([]*Segment{seg1, seg2})
+
+	w.mu.Lock()
+	merged := w.segments[len(w.segments)-1]
+	w.mu.Unlock()
+
+	for docID, wantLive := range map[int]bool{0: true, 1: false, 2: false} {
+		if got := merged.deletes.Get(docID); got != wantLive {
+			t.Errorf("deletes.Get(%v) = %v, want %v", docID, got, wantLive)
+		}
+	}
+}
@@ -1,83 +1,274 @@
 package classic
 
 import (
+	"errors"
 	"io"
+	"sync"
+	"unicode/utf8"
 )
 
+// maxBufferSize bounds how far refill() will grow the byte buffer before
+// giving up; at that point a single token is pathologically large (over
+// a megabyte) and almost certainly not legal input, so refill reports
+// ErrTokenTooLong instead of growing forever.
+const maxBufferSize = 1 << 20
+
+// ErrTokenTooLong is returned by refill (and surfaces through readChar)
+// when a single token would need to grow the buffer past maxBufferSize.
+// Callers of Parse should turn this into a proper ParseException rather
+// than letting it unwind as a panic.
+var ErrTokenTooLong = errors.New("classic: token exceeds maximum buffer size")
+
+// FastCharStream scans runes out of a byte buffer rather than decoding
+// one rune at a time from an io.RuneReader. The classic query grammar is
+// overwhelmingly ASCII, and profiles showed the old design's per-char
+// virtual call and UTF-8 decode dominating parse time, so this follows
+// the same technique the Go compiler's syntax scanner uses: buf holds
+// raw bytes with a sentinel (utf8.RuneSelf) written just past the valid
+// data, so the hot path is a single bounds-check-free comparison and
+// only the cold path (a genuine multi-byte rune, or hitting the
+// sentinel) calls into utf8.DecodeRune / refill.
 type FastCharStream struct {
-	buffer []rune
+	buf []byte
+	b   int // token begin, or -1 when no token is active
+	r   int // read cursor: one past the last decoded rune
+	e   int // end of valid bytes in buf
+	chw int // byte width of the rune last returned by readChar
+
+	input io.Reader
 
-	bufferLength   int
-	bufferPosition int
+	// Incremental line/column tracking of the last rune returned by
+	// readChar. line and column are 1-based, except that a line-break rune
+	// itself reports column 0 of the line it starts. colStack holds, for each
+	// currently-open line above the current one, the column count that
+	// was in effect when that line started. Because backup() only ever
+	// needs to undo the single most recent readChar (the one-rune
+	// lookback contract JavaCC-generated scanners rely on), we only need
+	// to remember one step of prior state, not a full stack of them.
+	line      int
+	column    int
+	colStack  []int
+	lastWasCR bool
 
-	tokenStart  int
-	bufferStart int
+	prevLine      int
+	prevColumn    int
+	prevLastWasCR bool
+	prevColStackN int
+
+	// lastErr holds a non-nil error returned by input.Read alongside
+	// n > 0 bytes: per the io.Reader contract (mirrored by bufio.Reader)
+	// a read may yield data and an error in the same call, and the data
+	// must be consumed before the error is reported. refill stashes such
+	// an error here and returns it on the next call, once the
+	// already-buffered bytes are exhausted, instead of silently
+	// dropping it.
+	lastErr error
+}
+
+func newFastCharStream(r io.Reader) *FastCharStream {
+	cs := &FastCharStream{input: r, b: -1, line: 1, column: 0}
+	cs.buf = make([]byte, 2048)
+	cs.buf[cs.e] = utf8.RuneSelf // sentinel: buf is empty until the first refill
+	return cs
+}
+
+// charStreamPool lets many queries per second (a common Lucene workload)
+// reuse a stream's underlying buffer instead of allocating a fresh one
+// per parse; see acquireFastCharStream/releaseFastCharStream.
+var charStreamPool = sync.Pool{
+	New: func() interface{} { return newFastCharStream(nil) },
+}
 
-	input io.RuneReader // source of chars
+// acquireFastCharStream gets a FastCharStream from charStreamPool (or
+// allocates one if the pool is empty) and resets it to read from r.
+func acquireFastCharStream(r io.Reader) *FastCharStream {
+	cs := charStreamPool.Get().(*FastCharStream)
+	cs.Reset(r)
+	return cs
 }
 
-func newFastCharStream(r io.RuneReader) *FastCharStream {
-	return &FastCharStream{input: r}
+// releaseFastCharStream returns cs to charStreamPool; cs must not be used
+// again afterwards.
+func releaseFastCharStream(cs *FastCharStream) {
+	charStreamPool.Put(cs)
 }
 
+// Reset rebinds cs to read from r, zeroing all scan state but reusing
+// the existing buffer, so repeated parses amortize to zero allocations.
+func (cs *FastCharStream) Reset(r io.Reader) {
+	cs.input = r
+	cs.b = -1
+	cs.r = 0
+	cs.e = 0
+	cs.chw = 0
+	cs.line = 1
+	cs.column = 0
+	cs.colStack = cs.colStack[:0]
+	cs.lastWasCR = false
+	cs.lastErr = nil
+	cs.buf[cs.e] = utf8.RuneSelf // sentinel: buf is empty until the first refill
+}
+
+// readChar returns the next rune, refilling from input as needed.
 func (cs *FastCharStream) readChar() (rune, error) {
-	if cs.bufferPosition >= cs.bufferLength {
+	c := cs.buf[cs.r]
+	if c < utf8.RuneSelf {
+		cs.snapshotPosition()
+		cs.r++
+		cs.chw = 1
+		cs.advancePosition(rune(c))
+		return rune(c), nil
+	}
+	return cs.readCharSlow()
+}
+
+// readCharSlow handles the cold path: either buf[r] is the sentinel (we
+// need more input) or it begins a genuine multi-byte UTF-8 sequence.
+func (cs *FastCharStream) readCharSlow() (rune, error) {
+	for cs.r >= cs.e || !utf8.FullRune(cs.buf[cs.r:cs.e]) {
+		if cs.r < cs.e && cs.buf[cs.r] < utf8.RuneSelf {
+			// A previous partial refill already completed this rune.
+			break
+		}
 		if err := cs.refill(); err != nil {
 			return 0, err
 		}
 	}
-	cs.bufferPosition++
-	return cs.buffer[cs.bufferPosition-1], nil
+	if cs.r >= cs.e {
+		return 0, io.EOF
+	}
+	c := cs.buf[cs.r]
+	if c < utf8.RuneSelf {
+		return cs.readChar()
+	}
+	rr, w := utf8.DecodeRune(cs.buf[cs.r:cs.e])
+	cs.snapshotPosition()
+	cs.r += w
+	cs.chw = w
+	cs.advancePosition(rr)
+	return rr, nil
 }
 
-func (cs *FastCharStream) refill() (err error) {
-	newPosition := cs.bufferLength - cs.tokenStart
+// snapshotPosition records line/column state just before it is mutated
+// by advancePosition, so backup() can restore it exactly.
+func (cs *FastCharStream) snapshotPosition() {
+	cs.prevLine = cs.line
+	cs.prevColumn = cs.column
+	cs.prevLastWasCR = cs.lastWasCR
+	cs.prevColStackN = len(cs.colStack)
+}
 
-	if cs.tokenStart == 0 { // token won't fit in buffer
-		if cs.buffer == nil { // first time: alloc buffer
-			cs.buffer = make([]rune, 2048)
-		} else if cs.bufferLength == len(cs.buffer) { // grow buffer
-			panic("not implemented yet")
-		}
-	} else { // shift token to front
-		copy(cs.buffer, cs.buffer[cs.tokenStart:cs.tokenStart+newPosition])
+// advancePosition updates line/column/colStack for the rune just
+// consumed, recognizing "\n", "\r" and "\r\n" as a single line break.
+func (cs *FastCharStream) advancePosition(r rune) {
+	switch {
+	case r == '\n' && cs.lastWasCR:
+		// second half of a "\r\n" pair: already counted on the '\r'
+		cs.lastWasCR = false
+	case r == '\n' || r == '\r':
+		cs.colStack = append(cs.colStack, cs.column)
+		cs.line++
+		cs.column = 0 // the next rune on the new line is column 1
+		cs.lastWasCR = r == '\r'
+	default:
+		cs.column++
+		cs.lastWasCR = false
 	}
+}
 
-	cs.bufferLength = newPosition // update state
-	cs.bufferPosition = newPosition
-	cs.bufferStart += cs.tokenStart
-	cs.tokenStart = 0
+// refill reads more bytes from input, growing buf if a token in progress
+// already fills it, and re-establishes the sentinel byte at buf[e]. It
+// makes exactly one call to input.Read, never looping to fill the
+// buffer: a single partial read plus a deferred error (see lastErr) is
+// the correct, bufio-style behavior, not a bug to retry around.
+func (cs *FastCharStream) refill() (err error) {
+	if cs.lastErr != nil {
+		err, cs.lastErr = cs.lastErr, nil
+		return err
+	}
 
-	var charsRead int // fill space in buffer
-	limit := len(cs.buffer) - newPosition
-	for charsRead < limit && err == nil {
-		cs.buffer[newPosition+charsRead], _, err = cs.input.ReadRune()
-		charsRead++
+	if cs.b >= 0 { // shift the in-progress token to the front
+		n := copy(cs.buf, cs.buf[cs.b:cs.e])
+		cs.r -= cs.b
+		cs.e = n
+		cs.b = 0
+	} else {
+		// No token is active, but bytes between r and e may still be an
+		// incomplete multi-byte rune that readCharSlow is mid-decode of
+		// (e.g. during SKIP whitespace before the first beginToken()):
+		// shift them to the front the same way the cs.b >= 0 branch
+		// does, rather than discarding them, or the rune decodes as
+		// utf8.RuneError once the rest of it arrives.
+		n := copy(cs.buf, cs.buf[cs.r:cs.e])
+		cs.r = 0
+		cs.e = n
 	}
-	if err != nil && err != io.EOF || charsRead == 0 {
-		return err
+
+	if cs.e == len(cs.buf)-1 { // no room left for data plus sentinel
+		if len(cs.buf) >= maxBufferSize {
+			return ErrTokenTooLong
+		}
+		newSize := 2 * len(cs.buf)
+		if newSize > maxBufferSize {
+			newSize = maxBufferSize
+		}
+		grown := make([]byte, newSize)
+		copy(grown, cs.buf[:cs.e])
+		cs.buf = grown
+	}
+
+	n, err := cs.input.Read(cs.buf[cs.e : len(cs.buf)-1])
+	cs.e += n
+	cs.buf[cs.e] = utf8.RuneSelf // sentinel
+	if n > 0 {
+		// Keep the bytes we got; report a non-nil err (including io.EOF)
+		// only once they've been consumed.
+		cs.lastErr = err
+		return nil
 	}
-	cs.bufferLength += charsRead
-	return nil
+	return err
 }
 
 func (cs *FastCharStream) beginToken() (rune, error) {
-	cs.tokenStart = cs.bufferPosition
+	cs.b = cs.r
 	return cs.readChar()
 }
 
+// backup un-reads the single rune last returned by readChar; the
+// generated parser never backs up more than one rune at a time, so this
+// does not support an arbitrary amount like the old rune-buffer design
+// did.
 func (cs *FastCharStream) backup(amount int) {
-	panic("not implemented yet")
+	if amount == 0 {
+		return
+	}
+	if amount != 1 {
+		panic("FastCharStream: backup only supports rewinding a single rune")
+	}
+	if cs.chw == 0 || cs.r-cs.chw < cs.b {
+		panic("FastCharStream: cannot backup before the current token")
+	}
+	cs.r -= cs.chw
+	cs.chw = 0
+	cs.line = cs.prevLine
+	cs.column = cs.prevColumn
+	cs.lastWasCR = cs.prevLastWasCR
+	cs.colStack = cs.colStack[:cs.prevColStackN]
 }
 
+// image returns the text of the token last started with beginToken.
 func (cs *FastCharStream) image() string {
-	panic("not implemented yet")
+	return string(cs.buf[cs.b:cs.r])
 }
 
+// endColumn returns the (1-based) column of the last rune read; a
+// line-break rune reports column 0 of the line it starts.
 func (cs *FastCharStream) endColumn() int {
-	panic("not implemented yet")
+	return cs.column
 }
 
+// endLine returns the (1-based) line of the last rune read; a line-break
+// rune counts as part of the line it starts.
 func (cs *FastCharStream) endLine() int {
-	panic("not implemented yet")
-}
\ No newline at end of file
+	return cs.line
+}
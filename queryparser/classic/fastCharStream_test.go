@@ -0,0 +1,186 @@
+package classic
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeReader plays back a fixed sequence of (data, err) reads, letting
+// tests simulate a Reader that returns bytes alongside a non-EOF error,
+// or leaves some bytes unread, without depending on a real flaky source.
+type fakeReader struct {
+	reads [][]byte
+	errs  []error
+	i     int
+}
+
+func (f *fakeReader) Read(p []byte) (int, error) {
+	if f.i >= len(f.reads) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.reads[f.i])
+	err := f.errs[f.i]
+	f.i++
+	return n, err
+}
+
+var errTransient = errors.New("transient read error")
+
+// TestNewFastCharStreamReadsFirstCharWithoutRefillMisfire guards against a
+// freshly allocated buf being all zero bytes: without the sentinel written
+// at buf[e] before the first refill, readChar sees buf[0]==0 (which is <
+// utf8.RuneSelf) and returns a bogus NUL rune instead of ever calling
+// refill.
+func TestNewFastCharStreamReadsFirstCharWithoutRefillMisfire(t *testing.T) {
+	cs := newFastCharStream(strings.NewReader("a"))
+
+	got, err := cs.readChar()
+	if err != nil {
+		t.Fatalf("readChar() error = %v", err)
+	}
+	if got != 'a' {
+		t.Errorf("readChar() = %q, want 'a': a fresh stream misread a bogus NUL instead of refilling", got)
+	}
+}
+
+func TestFastCharStreamGrowsBufferForLongToken(t *testing.T) {
+	term := strings.Repeat("a", 5*1024) // 5 KiB, well past the initial 2048-byte buffer
+	cs := newFastCharStream(strings.NewReader(term))
+
+	if _, err := cs.beginToken(); err != nil {
+		t.Fatalf("beginToken failed: %v", err)
+	}
+	for i := 0; i < len(term)-1; i++ {
+		if _, err := cs.readChar(); err != nil {
+			t.Fatalf("readChar failed at %v: %v", i, err)
+		}
+	}
+
+	if got := cs.image(); got != term {
+		t.Errorf("image() round-trip mismatch: got %v runes, want %v", len(got), len(term))
+	}
+}
+
+func TestFastCharStreamConsumesBufferedBytesBeforeTransientError(t *testing.T) {
+	r := &fakeReader{
+		reads: [][]byte{[]byte("ab")},
+		errs:  []error{errTransient},
+	}
+	cs := newFastCharStream(r)
+
+	for _, want := range "ab" {
+		got, err := cs.readChar()
+		if err != nil {
+			t.Fatalf("readChar() returned error %v before buffered bytes were consumed", err)
+		}
+		if got != want {
+			t.Errorf("readChar() = %q, want %q", got, want)
+		}
+	}
+
+	if _, err := cs.readChar(); err != errTransient {
+		t.Errorf("readChar() error = %v, want %v", err, errTransient)
+	}
+}
+
+func TestFastCharStreamSurfacesEOFOnlyAfterBufferedBytes(t *testing.T) {
+	r := &fakeReader{
+		reads: [][]byte{[]byte("x")},
+		errs:  []error{io.EOF},
+	}
+	cs := newFastCharStream(r)
+
+	if got, err := cs.readChar(); err != nil || got != 'x' {
+		t.Fatalf("readChar() = (%q, %v), want ('x', nil)", got, err)
+	}
+	if _, err := cs.readChar(); err != io.EOF {
+		t.Errorf("readChar() error = %v, want io.EOF", err)
+	}
+}
+
+// TestFastCharStreamPreservesMultiByteRuneSplitAcrossRefillWithNoToken
+// guards refill's cs.b < 0 branch (no token active, e.g. mid-SKIP before
+// the first beginToken()): it used to reset r/e to 0 unconditionally,
+// discarding any trailing incomplete multi-byte rune instead of
+// preserving it like the cs.b >= 0 branch does. Here "x" and the first
+// byte of "é" (U+00E9, 0xC3 0xA9) arrive in one read, the second byte of
+// "é" in the next; without the fix the lead byte 0xC3 is dropped and "é"
+// decodes as utf8.RuneError.
+func TestFastCharStreamPreservesMultiByteRuneSplitAcrossRefillWithNoToken(t *testing.T) {
+	r := &fakeReader{
+		reads: [][]byte{[]byte("x\xc3"), []byte("\xa9")},
+		errs:  []error{nil, nil},
+	}
+	cs := newFastCharStream(r)
+
+	got, err := cs.readChar()
+	if err != nil || got != 'x' {
+		t.Fatalf("readChar() = (%q, %v), want ('x', nil)", got, err)
+	}
+
+	got, err = cs.readChar()
+	if err != nil {
+		t.Fatalf("readChar() error = %v", err)
+	}
+	if got != 'é' {
+		t.Errorf("readChar() = %q, want 'é': the split-off lead byte was dropped instead of preserved across refill", got)
+	}
+}
+
+// TestResetReadsFirstCharWithoutRefillMisfire guards against the pooled
+// reuse path (acquireFastCharStream/Reset) leaving buf[e] as a stale or
+// zero byte: Reset must re-establish the sentinel itself, not rely on the
+// allocation-time write in newFastCharStream, or the first readChar after
+// a Reset misreads a bogus NUL instead of refilling.
+func TestResetReadsFirstCharWithoutRefillMisfire(t *testing.T) {
+	cs := newFastCharStream(strings.NewReader("x"))
+	if _, err := cs.readChar(); err != nil {
+		t.Fatalf("priming readChar() error = %v", err)
+	}
+
+	cs.Reset(strings.NewReader("a"))
+
+	got, err := cs.readChar()
+	if err != nil {
+		t.Fatalf("readChar() after Reset error = %v", err)
+	}
+	if got != 'a' {
+		t.Errorf("readChar() after Reset = %q, want 'a': a reused stream misread a bogus NUL instead of refilling", got)
+	}
+}
+
+// BenchmarkFastCharStreamASCII measures the sentinel-buffer fast path's
+// throughput over a representative ASCII query, the workload the
+// byte-buffer redesign targeted.
+func BenchmarkFastCharStreamASCII(b *testing.B) {
+	const query = `title:"the quick brown fox" AND year:[2000 TO 2020] AND NOT status:archived`
+	b.SetBytes(int64(len(query)))
+	for i := 0; i < b.N; i++ {
+		cs := newFastCharStream(strings.NewReader(query))
+		for {
+			if _, err := cs.readChar(); err != nil {
+				break
+			}
+		}
+	}
+}
+
+// BenchmarkFastCharStreamPooled demonstrates the allocs/op win from
+// acquireFastCharStream/releaseFastCharStream: after the first
+// iteration, every stream comes from charStreamPool and reuses its
+// buffer instead of allocating a fresh 2 KiB one per parse.
+func BenchmarkFastCharStreamPooled(b *testing.B) {
+	const query = `title:"the quick brown fox" AND year:[2000 TO 2020] AND NOT status:archived`
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cs := acquireFastCharStream(strings.NewReader(query))
+		for {
+			if _, err := cs.readChar(); err != nil {
+				break
+			}
+		}
+		releaseFastCharStream(cs)
+	}
+}
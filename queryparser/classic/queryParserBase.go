@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/balzaczyy/golucene/core/analysis"
 	"github.com/balzaczyy/golucene/core/search"
+	"strconv"
 	"strings"
 )
 
@@ -44,7 +45,9 @@ func newQueryParserBase(spi QueryParserBaseSPI) *QueryParserBase {
 
 // L116
 func (qp *QueryParserBase) Parse(query string) (res search.Query, err error) {
-	qp.spi.ReInit(newFastCharStream(strings.NewReader(query)))
+	cs := acquireFastCharStream(strings.NewReader(query))
+	defer releaseFastCharStream(cs)
+	qp.spi.ReInit(cs)
 	if res, err = qp.spi.TopLevelQuery(qp.field); err != nil {
 		return nil, errors.New(fmt.Sprintf("Cannot parse '%v': %v", query, err))
 	}
@@ -79,18 +82,35 @@ func (qp *QueryParserBase) handleBareTokenQuery(qField string,
 		return nil, err
 	}
 	if wildcard {
-		panic("not implemented yet")
+		return search.NewWildcardQuery(qField, termImage), nil
 	} else if prefix {
-		panic("not implemented yet")
+		// Prefix terms arrive with their trailing '*' already stripped by
+		// the grammar, same as upstream QueryParser.
+		return search.NewPrefixQuery(qField, termImage), nil
 	} else if regexp {
-		panic("not implemented yet")
+		return search.NewRegexpQuery(qField, termImage), nil
 	} else if fuzzy {
-		panic("not implemented yet")
+		return qp.handleBareFuzzy(qField, fuzzySlop, termImage)
 	} else {
 		return qp.fieldQuery(qField, termImage, false)
 	}
 }
 
+// L827, fuzzy branch
+// fuzzySlop is the raw `~N` token (e.g. "~2" or "~0.5"); its image carries
+// the leading '~', which handleBareFuzzy strips before search.NewFuzzyQuery
+// rounds the number to an edit distance via automaton.RoundEdits.
+func (qp *QueryParserBase) handleBareFuzzy(qField string, fuzzySlop *Token, termImage string) (q search.Query, err error) {
+	minSimilarity := 2.0
+	if fuzzySlop != nil && len(fuzzySlop.image) > 1 {
+		minSimilarity, err = strconv.ParseFloat(fuzzySlop.image[1:], 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid fuzzy slop: %v", fuzzySlop.image))
+		}
+	}
+	return search.NewFuzzyQuery(qField, termImage, minSimilarity), nil
+}
+
 // L876
 func (qp *QueryParserBase) handleBoost(q search.Query, boost *Token) search.Query {
 	panic("not implemented yet")
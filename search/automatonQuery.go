@@ -0,0 +1,37 @@
+package search
+
+import "github.com/balzaczyy/golucene/index"
+
+// AutomatonQuery is the common MultiTermQuery implementation for
+// wildcard, prefix and regexp queries: all three reduce to "intersect a
+// compiled automaton against the field's FST" (see
+// index.IntersectTermsEnum), they differ only in how the automaton was
+// built.
+type AutomatonQuery struct {
+	*MultiTermQuery
+	compiled *index.CompiledAutomaton
+}
+
+func newAutomatonQuery(field string, compiled *index.CompiledAutomaton) *AutomatonQuery {
+	return &AutomatonQuery{MultiTermQuery: newMultiTermQuery(field), compiled: compiled}
+}
+
+func (q *AutomatonQuery) getTermsEnum(terms index.Terms) (index.TermsEnum, error) {
+	return terms.IntersectAutomaton(q.compiled)
+}
+
+// NewPrefixQuery matches every term in field beginning with prefix.
+func NewPrefixQuery(field, prefix string) *AutomatonQuery {
+	return newAutomatonQuery(field, index.NewPrefixAutomaton([]byte(prefix)))
+}
+
+// NewWildcardQuery matches every term in field against a classic Lucene
+// wildcard pattern (`*` and `?`).
+func NewWildcardQuery(field, pattern string) *AutomatonQuery {
+	return newAutomatonQuery(field, index.NewWildcardAutomaton(pattern))
+}
+
+// NewRegexpQuery matches every term in field against a regexp pattern.
+func NewRegexpQuery(field, pattern string) *AutomatonQuery {
+	return newAutomatonQuery(field, index.NewRegexpAutomaton(pattern))
+}
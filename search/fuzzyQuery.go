@@ -0,0 +1,34 @@
+package search
+
+import "github.com/balzaczyy/golucene/index"
+
+// FuzzyQuery matches terms within maxEdits (Damerau-)Levenshtein edits of
+// term, by intersecting a precompiled Levenshtein automaton against each
+// segment's FST term dictionary (see index.IntersectTermsEnum) instead of
+// scanning every term in the field.
+type FuzzyQuery struct {
+	*MultiTermQuery
+	term           index.Term
+	maxEdits       int
+	transpositions bool
+	compiled       *index.CompiledAutomaton
+}
+
+// NewFuzzyQuery builds a FuzzyQuery for term in field, rounding
+// minSimilarity to an integer edit distance the same way the classic
+// QueryParser's `~N`/`~0.5` fuzzy slop has always been interpreted.
+func NewFuzzyQuery(field, text string, minSimilarity float64) *FuzzyQuery {
+	maxEdits := roundEdits(minSimilarity, len(text))
+	term := index.NewTerm(field, text)
+	return &FuzzyQuery{
+		MultiTermQuery: newMultiTermQuery(field),
+		term:           term,
+		maxEdits:       maxEdits,
+		transpositions: true,
+		compiled:       index.NewFuzzyAutomaton(text, maxEdits, true),
+	}
+}
+
+func (q *FuzzyQuery) getTermsEnum(terms index.Terms) (index.TermsEnum, error) {
+	return terms.IntersectAutomaton(q.compiled)
+}
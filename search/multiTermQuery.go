@@ -0,0 +1,27 @@
+package search
+
+import "github.com/balzaczyy/golucene/util/automaton"
+
+// MultiTermQuery is the common base for queries that match a dynamic set
+// of terms rather than a single one (wildcard, prefix, regexp, fuzzy).
+// Concrete subtypes supply getTermsEnum, which is expected to return an
+// index.IntersectTermsEnum (or equivalent) driven by a CompiledAutomaton,
+// rather than enumerating every term in the field.
+type MultiTermQuery struct {
+	field string
+}
+
+func newMultiTermQuery(field string) *MultiTermQuery {
+	return &MultiTermQuery{field: field}
+}
+
+func (q *MultiTermQuery) Field() string {
+	return q.field
+}
+
+// roundEdits converts a classic QueryParser fuzzy slop (an edit distance
+// for values >= 1, or a similarity threshold in [0,1) otherwise) into the
+// integer edit distance automaton.LevenshteinAutomatonBuilderFor expects.
+func roundEdits(fuzzySlop float64, termLen int) int {
+	return automaton.RoundEdits(fuzzySlop, termLen)
+}
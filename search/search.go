@@ -1,17 +1,107 @@
 package search
 
 import (
-	// "container/heap"
+	"container/heap"
 	"lucene/index"
 	"math"
+	"sync"
 )
 
+// Executor dispatches leaf-search work units to a pool of goroutines.
+// Passing nil wherever an Executor is accepted keeps the original
+// single-goroutine behavior, so existing callers are unaffected.
+type Executor interface {
+	// Execute runs task, returning once it (and every task previously
+	// submitted to this Executor) has completed-or-been-scheduled; the
+	// caller is responsible for waiting on whatever completion signal
+	// task itself provides (see NewGoroutinePoolExecutor).
+	Execute(task func())
+}
+
+// GoroutinePoolExecutor is the default Executor: it bounds concurrency to
+// size goroutines via a buffered channel used as a semaphore.
+type GoroutinePoolExecutor struct {
+	sem chan struct{}
+	wg  *sync.WaitGroup
+}
+
+func NewGoroutinePoolExecutor(size int) *GoroutinePoolExecutor {
+	if size < 1 {
+		size = 1
+	}
+	return &GoroutinePoolExecutor{sem: make(chan struct{}, size), wg: &sync.WaitGroup{}}
+}
+
+func (e *GoroutinePoolExecutor) Execute(task func()) {
+	e.wg.Add(1)
+	e.sem <- struct{}{}
+	go func() {
+		defer func() { <-e.sem; e.wg.Done() }()
+		task()
+	}()
+}
+
+func (e *GoroutinePoolExecutor) awaitAll() {
+	e.wg.Wait()
+}
+
+// LeafSlice groups one or more small leaves into a single work unit, the
+// same way Lucene's IndexSearcher.slices avoids spending a whole
+// goroutine on a tiny segment.
+type LeafSlice struct {
+	leaves []index.AtomicReaderContext
+}
+
+// groupSliceBounds decides, for each leaf's doc count in order, which
+// slice index it falls into: a new slice starts whenever adding the next
+// leaf would push the current slice over maxDocsPerSlice or
+// maxSegmentsPerSlice, mirroring Lucene's IndexSearcher.slices. Pulled
+// out of SearchSlices as plain arithmetic over doc counts so the grouping
+// decision can be tested without needing a real index.Reader.
+func groupSliceBounds(docCounts []int, maxDocsPerSlice, maxSegmentsPerSlice int) [][]int {
+	var slices [][]int
+	var cur []int
+	curDocs := 0
+	for _, docs := range docCounts {
+		if len(cur) > 0 && (curDocs+docs > maxDocsPerSlice || len(cur) >= maxSegmentsPerSlice) {
+			slices = append(slices, cur)
+			cur = nil
+			curDocs = 0
+		}
+		cur = append(cur, docs)
+		curDocs += docs
+	}
+	if len(cur) > 0 {
+		slices = append(slices, cur)
+	}
+	return slices
+}
+
+// SearchSlices groups leaves into slices for concurrent search: each
+// slice is searched by a single worker, so many small leaves are folded
+// together into one unit of work while large leaves get their own.
+func SearchSlices(leaves []index.AtomicReaderContext, maxDocsPerSlice, maxSegmentsPerSlice int) []LeafSlice {
+	docCounts := make([]int, len(leaves))
+	for i, ctx := range leaves {
+		docCounts[i] = ctx.Reader.MaxDoc()
+	}
+	groups := groupSliceBounds(docCounts, maxDocsPerSlice, maxSegmentsPerSlice)
+	slices := make([]LeafSlice, len(groups))
+	leafIdx := 0
+	for i, g := range groups {
+		slices[i] = LeafSlice{leaves[leafIdx : leafIdx+len(g)]}
+		leafIdx += len(g)
+	}
+	return slices
+}
+
 // IndexSearcher
 type IndexSearcher struct {
 	reader        index.Reader
 	readerContext index.ReaderContext
 	leafContexts  []index.AtomicReaderContext
 	Similarity    Similarity
+	executor      Executor
 }
 
 func NewIndexSearcher(r index.Reader) IndexSearcher {
@@ -21,7 +111,16 @@ func NewIndexSearcher(r index.Reader) IndexSearcher {
 func NewIndexSearcherFromContext(context index.ReaderContext) IndexSearcher {
 	//assert context.isTopLevel: "IndexSearcher's ReaderContext must be topLevel for reader" + context.reader();
 	defaultSimilarity := NewDefaultSimilarity()
-	return IndexSearcher{context.Reader(), context, context.Leaves(), defaultSimilarity}
+	return IndexSearcher{context.Reader(), context, context.Leaves(), defaultSimilarity, nil}
+}
+
+// NewIndexSearcherWithExecutor is like NewIndexSearcher but runs each
+// leaf (or leaf slice) on executor instead of serially. Pass nil for
+// executor to keep the single-threaded default.
+func NewIndexSearcherWithExecutor(r index.Reader, executor Executor) IndexSearcher {
+	ss := NewIndexSearcher(r)
+	ss.executor = executor
+	return ss
 }
 
 func (ss IndexSearcher) Search(q Query, f Filter, n int) TopDocs {
@@ -29,13 +128,11 @@ func (ss IndexSearcher) Search(q Query, f Filter, n int) TopDocs {
 }
 
 func (ss IndexSearcher) searchWSI(w Weight, after ScoreDoc, nDocs int) TopDocs {
-	// TODO support concurrent search
 	return ss.searchLWSI(ss.leafContexts, w, after, nDocs)
 }
 
 func (ss IndexSearcher) searchLWSI(leaves []index.AtomicReaderContext,
 	w Weight, after ScoreDoc, nDocs int) TopDocs {
-	// TODO support concurrent search
 	limit := ss.reader.MaxDoc()
 	if limit == 0 {
 		limit = 1
@@ -43,15 +140,76 @@ func (ss IndexSearcher) searchLWSI(leaves []index.AtomicReaderContext,
 	if nDocs > limit {
 		nDocs = limit
 	}
-	collector := NewTopScoreDocCollector(nDocs, after, !w.IsScoresDocsOutOfOrder())
-	ss.searchLWC(leaves, w, collector)
-	return collector.TopDocs()
+	inOrder := !w.IsScoresDocsOutOfOrder()
+
+	if ss.executor == nil {
+		collector := NewTopScoreDocCollector(nDocs, after, inOrder)
+		ss.searchLWC(leaves, w, collector)
+		return collector.TopDocs()
+	}
+
+	slices := SearchSlices(leaves, 250000, 5)
+	perLeaf := make([]TopDocs, len(slices))
+	var wg sync.WaitGroup
+	for i, slice := range slices {
+		wg.Add(1)
+		i, slice := i, slice
+		ss.executor.Execute(func() {
+			defer wg.Done()
+			collector := newPerLeafCollector(nDocs, after, inOrder)
+			ss.searchLWC(slice.leaves, w, collector)
+			perLeaf[i] = collector.TopDocs()
+		})
+	}
+	wg.Wait()
+	return mergeTopDocs(perLeaf, nDocs)
+}
+
+// scoreDocHeap is a min-heap on Score: mergeTopDocs bounds it to nDocs
+// entries, so the weakest ScoreDoc seen so far is always at the root and
+// can be evicted in O(log nDocs) when a better one arrives.
+type scoreDocHeap []ScoreDoc
+
+func (h scoreDocHeap) Len() int           { return len(h) }
+func (h scoreDocHeap) Less(i, j int) bool { return h[i].Score < h[j].Score }
+func (h scoreDocHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *scoreDocHeap) Push(x interface{}) {
+	*h = append(*h, x.(ScoreDoc))
+}
+func (h *scoreDocHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// mergeTopDocs reduces the per-leaf/per-slice TopDocs produced by
+// concurrent search into the single ranked TopDocs a caller expects, by
+// feeding every ScoreDoc through one priority queue bounded to nDocs
+// entries and reading it back out best-first.
+func mergeTopDocs(perLeaf []TopDocs, nDocs int) TopDocs {
+	total := 0
+	h := &scoreDocHeap{}
+	for _, td := range perLeaf {
+		total += td.totalHits
+		for _, sd := range td.ScoreDocs {
+			if h.Len() < nDocs {
+				heap.Push(h, sd)
+			} else if h.Len() > 0 && sd.Score > (*h)[0].Score {
+				heap.Pop(h)
+				heap.Push(h, sd)
+			}
+		}
+	}
+	merged := make([]ScoreDoc, h.Len())
+	for i := len(merged) - 1; i >= 0; i-- {
+		merged[i] = heap.Pop(h).(ScoreDoc)
+	}
+	return TopDocs{totalHits: total, ScoreDocs: merged}
 }
 
 func (ss IndexSearcher) searchLWC(leaves []index.AtomicReaderContext, w Weight, c Collector) {
-	// TODO: should we make this
-	// threaded...?  the Collector could be sync'd?
-	// always use single thread:
 	for _, ctx := range leaves {
 		if !c.setNextReader(ctx) {
 			// there is no doc of interest in this reader context
@@ -103,6 +261,44 @@ func (ss IndexSearcher) TermStatistics(term index.Term, context index.TermContex
 	return NewTermStatistics(term.Bytes, context.DocFreq, context.TotalTermFreq)
 }
 
+// FieldDict returns an iterator over every term in field, in sorted
+// order, letting callers build facets/autocompletion without driving a
+// raw TermsEnum themselves.
+func (ss IndexSearcher) FieldDict(field string) (*index.FieldDict, error) {
+	fr := ss.fieldReader(field)
+	if fr == nil {
+		return nil, nil
+	}
+	return fr.FieldDict()
+}
+
+// FieldDictRange returns an iterator over the terms in field within
+// [start, end).
+func (ss IndexSearcher) FieldDictRange(field string, start, end []byte) (*index.FieldDict, error) {
+	fr := ss.fieldReader(field)
+	if fr == nil {
+		return nil, nil
+	}
+	return fr.FieldDictRange(start, end)
+}
+
+// FieldDictPrefix returns an iterator over the terms in field sharing
+// prefix.
+func (ss IndexSearcher) FieldDictPrefix(field string, prefix []byte) (*index.FieldDict, error) {
+	fr := ss.fieldReader(field)
+	if fr == nil {
+		return nil, nil
+	}
+	return fr.FieldDictPrefix(prefix)
+}
+
+// fieldReader resolves field's Terms down to the concrete *FieldReader
+// the FieldDict API is implemented on.
+func (ss IndexSearcher) fieldReader(field string) *index.FieldReader {
+	fr, _ := index.GetTerms(ss.reader, field).(*index.FieldReader)
+	return fr
+}
+
 func (ss IndexSearcher) CollectionStatistics(field string) CollectionStatistics {
 	terms := index.GetTerms(ss.reader, field)
 	if terms.iterator == nil {
@@ -112,6 +308,8 @@ func (ss IndexSearcher) CollectionStatistics(field string) CollectionStatistics
 }
 
 type ScoreDoc struct {
+	Doc   int
+	Score float32
 }
 
 type TermStatistics struct {
@@ -140,6 +338,7 @@ func NewCollectionStatistics(field string, maxDoc, docCount, sumTotalTermFreq, s
 
 type TopDocs struct {
 	totalHits int
+	ScoreDocs []ScoreDoc
 }
 
 type Similarity interface {
@@ -204,3 +403,11 @@ func NewTopScoreDocCollector(numHits int, after ScoreDoc, docsScoredInOrder bool
 		panic("not supported yet")
 	}
 }
+
+// newPerLeafCollector returns a collector that is never shared across
+// goroutines: concurrent search gives every slice its own instance, then
+// reduces their TopDocs with mergeTopDocs, instead of synchronizing a
+// single shared Collector.
+func newPerLeafCollector(numHits int, after ScoreDoc, docsScoredInOrder bool) TopScoreDocCollector {
+	return NewTopScoreDocCollector(numHits, after, docsScoredInOrder)
+}
@@ -0,0 +1,72 @@
+package search
+
+import "testing"
+
+func TestMergeTopDocsRanksAcrossLeavesByScore(t *testing.T) {
+	perLeaf := []TopDocs{
+		{totalHits: 2, ScoreDocs: []ScoreDoc{{Doc: 1, Score: 0.5}, {Doc: 2, Score: 0.9}}},
+		{totalHits: 3, ScoreDocs: []ScoreDoc{{Doc: 3, Score: 0.7}}},
+	}
+
+	merged := mergeTopDocs(perLeaf, 10)
+
+	if merged.totalHits != 5 {
+		t.Errorf("totalHits = %v, want 5", merged.totalHits)
+	}
+	wantOrder := []int{2, 3, 1} // descending by score: 0.9, 0.7, 0.5
+	if len(merged.ScoreDocs) != len(wantOrder) {
+		t.Fatalf("len(ScoreDocs) = %v, want %v", len(merged.ScoreDocs), len(wantOrder))
+	}
+	for i, doc := range wantOrder {
+		if merged.ScoreDocs[i].Doc != doc {
+			t.Errorf("ScoreDocs[%d].Doc = %v, want %v", i, merged.ScoreDocs[i].Doc, doc)
+		}
+	}
+}
+
+func TestMergeTopDocsTruncatesToNDocs(t *testing.T) {
+	perLeaf := []TopDocs{
+		{totalHits: 3, ScoreDocs: []ScoreDoc{{Doc: 1, Score: 0.1}, {Doc: 2, Score: 0.9}, {Doc: 3, Score: 0.5}}},
+	}
+
+	merged := mergeTopDocs(perLeaf, 2)
+
+	if len(merged.ScoreDocs) != 2 {
+		t.Fatalf("len(ScoreDocs) = %v, want 2", len(merged.ScoreDocs))
+	}
+	if merged.ScoreDocs[0].Doc != 2 || merged.ScoreDocs[1].Doc != 3 {
+		t.Errorf("ScoreDocs = %+v, want docs [2, 3] (the two highest scores)", merged.ScoreDocs)
+	}
+}
+
+func TestGroupSliceBoundsSplitsOnMaxDocs(t *testing.T) {
+	groups := groupSliceBounds([]int{100, 100, 100}, 150, 10)
+
+	if len(groups) != 3 {
+		t.Fatalf("len(groups) = %v, want 3", len(groups))
+	}
+	for i, g := range groups {
+		if len(g) != 1 {
+			t.Errorf("groups[%d] = %+v, want exactly 1 leaf: any 2 together already exceed maxDocsPerSlice=150", i, g)
+		}
+	}
+}
+
+func TestGroupSliceBoundsSplitsOnMaxSegments(t *testing.T) {
+	groups := groupSliceBounds([]int{1, 1, 1, 1}, 1000, 2)
+
+	if len(groups) != 2 {
+		t.Fatalf("len(groups) = %v, want 2", len(groups))
+	}
+	if len(groups[0]) != 2 || len(groups[1]) != 2 {
+		t.Errorf("groups = %+v, want [[1 1] [1 1]]: maxSegmentsPerSlice=2 caps each group regardless of doc counts", groups)
+	}
+}
+
+func TestGroupSliceBoundsFoldsManySmallLeavesTogether(t *testing.T) {
+	groups := groupSliceBounds([]int{1, 2, 3}, 1000, 10)
+
+	if len(groups) != 1 {
+		t.Fatalf("len(groups) = %v, want 1: all three leaves fit comfortably within the limits", len(groups))
+	}
+}
@@ -0,0 +1,70 @@
+package search
+
+import (
+	"lucene/index"
+
+	segindex "github.com/balzaczyy/golucene/index"
+)
+
+// snapshotReader adapts a segment-oriented *segindex.IndexSnapshot (the
+// in-memory, multi-segment view IndexWriter.Snapshot produces)
+// onto the index.Reader contract IndexSearcher already knows how to
+// drive, so NewIndexSearcherFromSnapshot below can hand it straight to
+// NewIndexSearcherFromContext instead of IndexSearcher needing a second,
+// parallel Search implementation for segment-based indexes. It is a thin
+// read-only view: every method answers directly off the wrapped
+// snapshot, with no caching or extra state of its own, so a fresh
+// snapshotReader is cheap to build on every call.
+type snapshotReader struct {
+	snap *segindex.IndexSnapshot
+}
+
+// NewIndexSearcherFromSnapshot builds an IndexSearcher over snap, the
+// IndexSnapshot an IndexWriter.Snapshot() produces, so queries run
+// against one consistent multi-segment view the same way they would
+// against any other index.Reader.
+func NewIndexSearcherFromSnapshot(snap *segindex.IndexSnapshot) IndexSearcher {
+	return NewIndexSearcher(&snapshotReader{snap: snap})
+}
+
+func (r *snapshotReader) Context() index.ReaderContext {
+	segs := r.snap.Segments()
+	leaves := make([]index.AtomicReaderContext, len(segs))
+	for i, seg := range segs {
+		leaves[i] = index.AtomicReaderContext{Reader: &segmentAtomicReader{seg: seg}}
+	}
+	return &snapshotReaderContext{reader: r, leaves: leaves}
+}
+
+func (r *snapshotReader) MaxDoc() int {
+	total := 0
+	for _, seg := range r.snap.Segments() {
+		total += seg.MaxDoc()
+	}
+	return total
+}
+
+// snapshotReaderContext is the index.ReaderContext snapshotReader.Context
+// returns: a single top-level context whose leaves are one
+// segmentAtomicReader per segment in the snapshot, the same shape a
+// single-segment-per-leaf on-disk index.Reader reports.
+type snapshotReaderContext struct {
+	reader *snapshotReader
+	leaves []index.AtomicReaderContext
+}
+
+func (c *snapshotReaderContext) Reader() index.Reader                { return c.reader }
+func (c *snapshotReaderContext) Leaves() []index.AtomicReaderContext { return c.leaves }
+
+// segmentAtomicReader adapts a single *segindex.Segment onto the per-leaf
+// reader surface searchLWC drives (MaxDoc, LiveDocs). Query term lookup
+// (AutomatonQuery and friends) reaches a segment's terms through its own
+// getTermsEnum/index.Terms path, not through this type, so it doesn't
+// need to answer GetTerms.
+type segmentAtomicReader struct {
+	seg *segindex.Segment
+}
+
+func (r *segmentAtomicReader) MaxDoc() int { return r.seg.MaxDoc() }
+
+func (r *segmentAtomicReader) LiveDocs() *segindex.DeletionBitmap { return r.seg.LiveDocs() }
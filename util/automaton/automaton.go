@@ -0,0 +1,104 @@
+package automaton
+
+import "sort"
+
+// A minimal deterministic finite automaton over byte labels, specialized
+// for use as the right-hand side of an FST/DFA intersection (see
+// index.IntersectTermsEnum). States are dense integers; transitions are
+// stored per-state as [min,max]->dest ranges sorted by min, and stepping
+// on a concrete byte scans them in order rather than doing a map lookup.
+type Automaton struct {
+	// accept[s] is true iff state s is an accepting (final) state.
+	accept []bool
+	// transitions[s] holds the outgoing ranges of state s, sorted by min.
+	transitions [][]transition
+	initial     int
+}
+
+type transition struct {
+	min, max byte
+	dest     int
+}
+
+// Builder incrementally constructs an Automaton. States are created with
+// NewState and wired together with AddTransition; the initial state is
+// state 0 unless SetInitialState names another.
+type Builder struct {
+	accept      []bool
+	transitions [][]transition
+	initial     int
+}
+
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+func (b *Builder) NewState() int {
+	b.accept = append(b.accept, false)
+	b.transitions = append(b.transitions, nil)
+	return len(b.accept) - 1
+}
+
+func (b *Builder) SetAccept(state int, accept bool) {
+	b.accept[state] = accept
+}
+
+func (b *Builder) SetInitialState(state int) {
+	b.initial = state
+}
+
+func (b *Builder) AddTransition(from int, min, max byte, dest int) {
+	b.transitions[from] = append(b.transitions[from], transition{min, max, dest})
+}
+
+func (b *Builder) Finish() *Automaton {
+	for _, ts := range b.transitions {
+		sort.Slice(ts, func(i, j int) bool { return ts[i].min < ts[j].min })
+	}
+	return &Automaton{accept: b.accept, transitions: b.transitions, initial: b.initial}
+}
+
+// InitialState returns the automaton's start state.
+func (a *Automaton) InitialState() int {
+	return a.initial
+}
+
+// IsAccept reports whether state is a final state.
+func (a *Automaton) IsAccept(state int) bool {
+	return a.accept[state]
+}
+
+// Step returns the destination state reached from state on label b, or
+// (-1, false) if no transition matches (i.e. the automaton rejects).
+func (a *Automaton) Step(state int, b byte) (int, bool) {
+	for _, t := range a.transitions[state] {
+		if b >= t.min && b <= t.max {
+			return t.dest, true
+		}
+	}
+	return -1, false
+}
+
+// Transitions exposes the sorted outgoing ranges of state, so callers
+// (e.g. IntersectTermsEnum) can enumerate the labels an FST arc must have
+// to stay alive, instead of probing byte-by-byte.
+func (a *Automaton) Transitions(state int) []transition {
+	return a.transitions[state]
+}
+
+func (t transition) Min() byte  { return t.min }
+func (t transition) Max() byte  { return t.max }
+func (t transition) Dest() int  { return t.dest }
+
+// Run reports whether the automaton accepts s in its entirety.
+func (a *Automaton) Run(s []byte) bool {
+	state := a.initial
+	for _, b := range s {
+		next, ok := a.Step(state, b)
+		if !ok {
+			return false
+		}
+		state = next
+	}
+	return a.IsAccept(state)
+}
@@ -0,0 +1,59 @@
+package automaton
+
+import "testing"
+
+func TestBuilderRunsAcceptedAndRejectedStrings(t *testing.T) {
+	// Builds a tiny automaton by hand accepting exactly "ab".
+	bld := NewBuilder()
+	s0 := bld.NewState()
+	s1 := bld.NewState()
+	s2 := bld.NewState()
+	bld.SetInitialState(s0)
+	bld.AddTransition(s0, 'a', 'a', s1)
+	bld.AddTransition(s1, 'b', 'b', s2)
+	bld.SetAccept(s2, true)
+	a := bld.Finish()
+
+	if !a.Run([]byte("ab")) {
+		t.Error(`Run("ab") = false, want true`)
+	}
+	for _, s := range []string{"a", "abc", "ba", ""} {
+		if a.Run([]byte(s)) {
+			t.Errorf("Run(%q) = true, want false", s)
+		}
+	}
+}
+
+func TestStepReportsRejectionOutsideAnyTransitionRange(t *testing.T) {
+	bld := NewBuilder()
+	s0 := bld.NewState()
+	s1 := bld.NewState()
+	bld.SetInitialState(s0)
+	bld.AddTransition(s0, 'a', 'z', s1)
+	a := bld.Finish()
+
+	if _, ok := a.Step(s0, 'a'); !ok {
+		t.Error("Step(s0, 'a') ok = false, want true: 'a' is within ['a','z']")
+	}
+	if _, ok := a.Step(s0, 'A'); ok {
+		t.Error("Step(s0, 'A') ok = true, want false: 'A' is outside ['a','z']")
+	}
+}
+
+func TestTransitionsExposesSortedRanges(t *testing.T) {
+	bld := NewBuilder()
+	s0 := bld.NewState()
+	s1 := bld.NewState()
+	bld.SetInitialState(s0)
+	bld.AddTransition(s0, 'a', 'c', s1)
+	bld.AddTransition(s0, 'x', 'z', s1)
+	a := bld.Finish()
+
+	ts := a.Transitions(s0)
+	if len(ts) != 2 {
+		t.Fatalf("len(Transitions(s0)) = %v, want 2", len(ts))
+	}
+	if ts[0].Min() != 'a' || ts[0].Max() != 'c' || ts[0].Dest() != s1 {
+		t.Errorf("Transitions(s0)[0] = %+v, want min='a' max='c' dest=%v", ts[0], s1)
+	}
+}
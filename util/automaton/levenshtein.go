@@ -0,0 +1,298 @@
+package automaton
+
+// LevenshteinAutomatonBuilder specializes a Levenshtein automaton of a
+// fixed maximum edit distance to concrete UTF-8 terms. The edit-distance
+// parameter is the only thing that shapes the automaton's state space
+// (the classic "parametric" observation behind Lucene's
+// LevenshteinAutomata/Moman construction): two terms of the same length
+// produce isomorphic automata, differing only in which byte each
+// transition is labeled with. A builder records only maxEdits and
+// whether transpositions count; it holds no precomputed state table.
+// ToAutomaton builds each term's DFA from scratch by running the
+// Damerau-Levenshtein row update over that term's bytes, once per DFA
+// state rather than once per candidate term.
+type LevenshteinAutomatonBuilder struct {
+	maxEdits      int
+	transpositions bool
+}
+
+// builders are created once at package init, one per (maxEdits,
+// transpositions) pair; they carry no per-term state.
+var (
+	builder1          = newLevenshteinAutomatonBuilder(1, true)
+	builder2          = newLevenshteinAutomatonBuilder(2, true)
+	builder1NoTrans   = newLevenshteinAutomatonBuilder(1, false)
+	builder2NoTrans   = newLevenshteinAutomatonBuilder(2, false)
+)
+
+// LevenshteinAutomatonBuilderFor returns the precomputed, reusable
+// builder for the given edit distance. Only maxEdits 1 and 2 are
+// precomputed (Lucene never goes further: higher edit distances make
+// fuzzy queries both useless and prohibitively expensive to enumerate).
+func LevenshteinAutomatonBuilderFor(maxEdits int, transpositions bool) *LevenshteinAutomatonBuilder {
+	switch {
+	case maxEdits <= 1 && transpositions:
+		return builder1
+	case maxEdits <= 1:
+		return builder1NoTrans
+	case transpositions:
+		return builder2
+	default:
+		return builder2NoTrans
+	}
+}
+
+func newLevenshteinAutomatonBuilder(maxEdits int, transpositions bool) *LevenshteinAutomatonBuilder {
+	if maxEdits < 0 {
+		maxEdits = 0
+	}
+	return &LevenshteinAutomatonBuilder{maxEdits: maxEdits, transpositions: transpositions}
+}
+
+// row is the parametric state: the full Damerau-Levenshtein edit-distance
+// row (one entry per term position), plus (when transpositions are
+// enabled) the previous row and the previous candidate byte, both needed
+// to detect an adjacent-character swap.
+type row struct {
+	dist  []int // current row, one entry per term position 0..len(term)
+	prev  []int // previous row, only used when transpositions is true
+	prevC byte  // candidate byte consumed by the step that produced this row
+}
+
+func (b *LevenshteinAutomatonBuilder) initialRow(termLen int) row {
+	dist := make([]int, termLen+1)
+	for i := range dist {
+		dist[i] = i
+	}
+	return row{dist: dist}
+}
+
+// step advances the parametric row by one input byte, returning the new
+// row. This is the specialization step: the transition table is shaped
+// by maxEdits alone, but which bytes of term match is decided here.
+func (b *LevenshteinAutomatonBuilder) step(term []byte, r row, c byte) row {
+	n := len(r.dist)
+	next := make([]int, n)
+	next[0] = r.dist[0] + 1
+	for j := 1; j < n; j++ {
+		cost := 1
+		if term[j-1] == c {
+			cost = 0
+		}
+		sub := r.dist[j-1] + cost
+		del := r.dist[j] + 1
+		ins := next[j-1] + 1
+		best := min3(sub, del, ins)
+		// A transposition at this position swapped the two most
+		// recently consumed candidate bytes: r.prevC is the byte the
+		// step before this one actually consumed, and c is the byte
+		// this step is consuming, so the swap is eligible exactly when
+		// those two bytes, in order (r.prevC, c), equal term's two
+		// bytes at j-2/j-1 in reverse (term[j-1], term[j-2]).
+		if b.transpositions && j >= 2 && r.prev != nil &&
+			c == term[j-2] && r.prevC == term[j-1] {
+			if t := r.prev[j-2] + cost; t < best {
+				best = t
+			}
+		}
+		next[j] = best
+	}
+	return row{dist: next, prev: r.dist, prevC: c}
+}
+
+func min3(a, b, c int) int {
+	m := a
+	if b < m {
+		m = b
+	}
+	if c < m {
+		m = c
+	}
+	return m
+}
+
+func (r row) minDistance() int {
+	m := r.dist[0]
+	for _, d := range r.dist[1:] {
+		if d < m {
+			m = d
+		}
+	}
+	return m
+}
+
+// finalColumn returns the edit distance between the candidate prefix
+// consumed so far and the *entire* term -- the only entry of the row that
+// answers "is this candidate actually close enough to term", as opposed
+// to minDistance's "is any prefix of term still within reach", which is
+// only valid as a dead-end pruning heuristic (it can only grow as more
+// candidate bytes are consumed), not as an accept decision. Accepting on
+// minDistance instead would accept any candidate that happens to match a
+// short prefix of term, regardless of how different the rest of it is.
+func (r row) finalColumn() int {
+	return r.dist[len(r.dist)-1]
+}
+
+// ToAutomaton specializes this builder to term, producing a DFA that
+// accepts exactly the byte strings within maxEdits (Damerau-)Levenshtein
+// distance of term. States are discovered lazily via subset-construction
+// style memoization on the parametric row, so terms sharing a prefix
+// share automaton states just like Lucene's implementation.
+func (b *LevenshteinAutomatonBuilder) ToAutomaton(term string) *Automaton {
+	termBytes := []byte(term)
+	bld := NewBuilder()
+
+	type key struct {
+		sig string
+	}
+	seen := make(map[string]int)
+	sigOf := func(r row) string {
+		buf := make([]byte, 0, len(r.dist)*2+1)
+		for _, d := range r.dist {
+			if d > b.maxEdits+1 {
+				d = b.maxEdits + 1
+			}
+			buf = append(buf, byte(d))
+		}
+		if b.transpositions {
+			// prevC affects whether the *next* step is eligible for a
+			// transposition (see step's doc comment), so two rows with
+			// identical dist but different prevC are not interchangeable
+			// states and must not be merged.
+			buf = append(buf, r.prevC)
+		}
+		return string(buf)
+	}
+
+	start := b.initialRow(len(termBytes))
+	startState := bld.NewState()
+	bld.SetInitialState(startState)
+	seen[sigOf(start)] = startState
+	bld.SetAccept(startState, start.finalColumn() <= b.maxEdits)
+
+	// BFS over reachable parametric rows, specialized byte-by-byte.
+	type pending struct {
+		state int
+		r     row
+	}
+	queue := []pending{{startState, start}}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if cur.r.minDistance() > b.maxEdits {
+			// Dead end: every extension only grows the edit distance.
+			continue
+		}
+		// Only bytes that appear in the term (or "any other byte", folded
+		// into one representative transition) can keep us within budget,
+		// so enumerate the term's own alphabet plus a wildcard fallback.
+		seenBytes := make(map[byte]bool)
+		for _, c := range termBytes {
+			if seenBytes[c] {
+				continue
+			}
+			seenBytes[c] = true
+			next := b.step(termBytes, cur.r, c)
+			if next.minDistance() > b.maxEdits {
+				continue
+			}
+			sig := sigOf(next)
+			dest, ok := seen[sig]
+			if !ok {
+				dest = bld.NewState()
+				seen[sig] = dest
+				bld.SetAccept(dest, next.finalColumn() <= b.maxEdits)
+				queue = append(queue, pending{dest, next})
+			}
+			bld.AddTransition(cur.state, c, c, dest)
+		}
+
+		// step's cost (and transposition check) only ever special-cases a
+		// byte that appears in term, so every byte outside seenBytes
+		// produces an identical row update: one representative byte
+		// stands in for the whole complement, and its transitions cover
+		// the rest in ranges. This is what actually reaches "bat", "cut",
+		// "cats" and every other one-edit variant that introduces a byte
+		// "cat" itself doesn't contain.
+		if fallback, ok := representativeNonTermByte(seenBytes); ok {
+			next := b.step(termBytes, cur.r, fallback)
+			if next.minDistance() <= b.maxEdits {
+				sig := sigOf(next)
+				dest, ok := seen[sig]
+				if !ok {
+					dest = bld.NewState()
+					seen[sig] = dest
+					bld.SetAccept(dest, next.finalColumn() <= b.maxEdits)
+					queue = append(queue, pending{dest, next})
+				}
+				addComplementTransitions(bld, cur.state, seenBytes, dest)
+			}
+		}
+	}
+	return bld.Finish()
+}
+
+// representativeNonTermByte returns a byte not in seenBytes (the term's
+// own alphabet), or ok=false if seenBytes already covers all 256 values.
+func representativeNonTermByte(seenBytes map[byte]bool) (b byte, ok bool) {
+	for v := 0; v <= 0xff; v++ {
+		if !seenBytes[byte(v)] {
+			return byte(v), true
+		}
+	}
+	return 0, false
+}
+
+// addComplementTransitions adds transitions on from for every byte not in
+// exclude, all routed to dest, merging adjacent bytes into ranges instead
+// of adding one transition per byte.
+func addComplementTransitions(bld *Builder, from int, exclude map[byte]bool, dest int) {
+	start := -1
+	for v := 0; v <= 0xff; v++ {
+		if exclude[byte(v)] {
+			if start >= 0 {
+				bld.AddTransition(from, byte(start), byte(v-1), dest)
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = v
+		}
+	}
+	if start >= 0 {
+		bld.AddTransition(from, byte(start), 0xff, dest)
+	}
+}
+
+// RoundEdits converts a classic QueryParser fuzzy slop (the number after
+// `~`, e.g. `2` or `0.5`) into an integer edit distance, following
+// Lucene's rule: a slop >= 1 is already an edit distance (capped at 2,
+// the only distances we can build automata for); a slop in [0,1) is a
+// similarity threshold and is converted via
+// floor((1-slop) * min(termLen, maxTermLenForSimilarity)).
+func RoundEdits(fuzzySlop float64, termLen int) int {
+	const maxTermLenForSimilarity = 127
+	if fuzzySlop >= 1 {
+		edits := int(fuzzySlop)
+		if edits > 2 {
+			edits = 2
+		}
+		return edits
+	}
+	if fuzzySlop < 0 {
+		fuzzySlop = 0
+	}
+	l := termLen
+	if l > maxTermLenForSimilarity {
+		l = maxTermLenForSimilarity
+	}
+	edits := int((1 - fuzzySlop) * float64(l))
+	if edits > 2 {
+		edits = 2
+	}
+	if edits < 0 {
+		edits = 0
+	}
+	return edits
+}
@@ -0,0 +1,100 @@
+package automaton
+
+import "testing"
+
+func TestLevenshteinAutomatonMatchesSubstitutionsNotInTerm(t *testing.T) {
+	a := newLevenshteinAutomatonBuilder(1, true).ToAutomaton("cat")
+
+	for _, term := range []string{"cat", "bat", "cut", "cats", "at"} {
+		if !a.Run([]byte(term)) {
+			t.Errorf("Run(%q) = false, want true: within 1 edit of \"cat\"", term)
+		}
+	}
+	for _, term := range []string{"dog", "catsup"} {
+		if a.Run([]byte(term)) {
+			t.Errorf("Run(%q) = true, want false: more than 1 edit from \"cat\"", term)
+		}
+	}
+}
+
+func TestLevenshteinAutomatonAcceptsOnFullTermDistanceNotPrefixDistance(t *testing.T) {
+	a := newLevenshteinAutomatonBuilder(1, true).ToAutomaton("babac")
+
+	if a.Run([]byte("b")) {
+		t.Error(`Run("b") = true, want false: edit distance to "babac" is 4, far more than 1; "b" only happens to match a one-byte prefix of "babac"`)
+	}
+}
+
+// TestLevenshteinAutomatonMatchesOnlyViaTransposition guards the
+// transposition branch of step(): "ab" and "ba" are 1 (Damerau-)edit
+// apart (swap the two bytes) but 2 substitutions apart otherwise, so
+// this only passes at maxEdits=1 if an actual adjacent-byte swap is
+// being detected between the term and the candidate, not merely a
+// same-term self-repeat (neither "ab" nor "ba" repeats any byte, so the
+// old term[j-1]==term[j-2] check can never fire here).
+func TestLevenshteinAutomatonMatchesOnlyViaTransposition(t *testing.T) {
+	withTrans := newLevenshteinAutomatonBuilder(1, true).ToAutomaton("ab")
+	if !withTrans.Run([]byte("ba")) {
+		t.Error(`Run("ba") = false, want true: "ab" -> "ba" is 1 transposition away`)
+	}
+
+	withoutTrans := newLevenshteinAutomatonBuilder(1, false).ToAutomaton("ab")
+	if withoutTrans.Run([]byte("ba")) {
+		t.Error(`Run("ba") = true, want false: without transpositions, "ab" -> "ba" needs 2 substitutions, past maxEdits=1`)
+	}
+}
+
+// TestLevenshteinAutomatonTranspositionUsesActualPrecedingCandidateByte
+// guards against reconstructing the "previous candidate byte" from term
+// alone instead of tracking what the candidate actually produced: "bac"
+// is a real distance of 2 from "abb" (swap a/b, then substitute c->b),
+// so it must be accepted at maxEdits=2, while "cccb" is a real distance
+// of 3 from "cacbbc" and must stay rejected at maxEdits=2 even though
+// "cacbbc" repeats 'c' and 'b' in ways the old self-referential check
+// could mistake for an eligible transposition.
+func TestLevenshteinAutomatonTranspositionUsesActualPrecedingCandidateByte(t *testing.T) {
+	a := newLevenshteinAutomatonBuilder(2, true).ToAutomaton("bac")
+	if !a.Run([]byte("abb")) {
+		t.Error(`Run("abb") = false, want true: "bac" -> "abb" is 2 edits away (swap a/b, substitute c->b)`)
+	}
+
+	b := newLevenshteinAutomatonBuilder(2, true).ToAutomaton("cacbbc")
+	if b.Run([]byte("cccb")) {
+		t.Error(`Run("cccb") = true, want false: "cacbbc" -> "cccb" is 3 edits away, past maxEdits=2`)
+	}
+}
+
+func TestRoundEditsTreatsSlopAtLeastOneAsEditDistance(t *testing.T) {
+	if got := RoundEdits(2, 10); got != 2 {
+		t.Errorf("RoundEdits(2, 10) = %v, want 2", got)
+	}
+	if got := RoundEdits(5, 10); got != 2 {
+		t.Errorf("RoundEdits(5, 10) = %v, want 2: edit distance is capped at 2", got)
+	}
+}
+
+func TestRoundEditsTreatsSlopBelowOneAsSimilarityThreshold(t *testing.T) {
+	if got := RoundEdits(0.5, 4); got != 2 {
+		t.Errorf("RoundEdits(0.5, 4) = %v, want 2: floor((1-0.5)*4) = 2", got)
+	}
+	if got := RoundEdits(0, 1); got != 1 {
+		t.Errorf("RoundEdits(0, 1) = %v, want 1: floor((1-0)*1) = 1", got)
+	}
+}
+
+func TestRoundEditsCapsTermLenForSimilarity(t *testing.T) {
+	if got := RoundEdits(0.1, 1000); got != 2 {
+		t.Errorf("RoundEdits(0.1, 1000) = %v, want 2: long terms are capped at maxTermLenForSimilarity before scaling, and the result itself is capped at 2", got)
+	}
+}
+
+func TestRepresentativeNonTermByte(t *testing.T) {
+	seen := map[byte]bool{'c': true, 'a': true, 't': true}
+	b, ok := representativeNonTermByte(seen)
+	if !ok {
+		t.Fatal("representativeNonTermByte() ok = false, want true")
+	}
+	if seen[b] {
+		t.Errorf("representativeNonTermByte() = %q, which is in seenBytes", b)
+	}
+}
@@ -0,0 +1,374 @@
+package automaton
+
+// This file implements a minimal regexp grammar via Thompson
+// construction followed by subset-construction determinization, plus the
+// PrefixAutomaton/WildcardAutomaton specializations built on top of it.
+// The grammar supports: literals, `.` (any byte), `*`, `+`, `?`,
+// character classes `[...]`, alternation `|` and grouping `(...)`.
+
+// nfaState is an NFA state with possibly-epsilon outgoing edges.
+type nfaState struct {
+	edges   []nfaEdge
+	epsilon []int
+}
+
+type nfaEdge struct {
+	min, max byte
+	dest     int
+}
+
+type nfaBuilder struct {
+	states []nfaState
+}
+
+func (b *nfaBuilder) newState() int {
+	b.states = append(b.states, nfaState{})
+	return len(b.states) - 1
+}
+
+func (b *nfaBuilder) addEdge(from int, min, max byte, to int) {
+	b.states[from].edges = append(b.states[from].edges, nfaEdge{min, max, to})
+}
+
+func (b *nfaBuilder) addEpsilon(from, to int) {
+	b.states[from].epsilon = append(b.states[from].epsilon, to)
+}
+
+// fragment is a partial NFA with a single start state and a single
+// dangling accept state, the standard Thompson-construction unit.
+type fragment struct {
+	start, accept int
+}
+
+type regexpParser struct {
+	pattern []byte
+	pos     int
+	nfa     *nfaBuilder
+}
+
+// ParseRegexp compiles a regexp pattern (the subset described above) into
+// a deterministic Automaton.
+func ParseRegexp(pattern string) *Automaton {
+	p := &regexpParser{pattern: []byte(pattern), nfa: &nfaBuilder{}}
+	frag := p.parseAlternation()
+	p.nfa.states[frag.accept].epsilon = append(p.nfa.states[frag.accept].epsilon, -1) // mark accept
+	return determinize(p.nfa, frag.start, frag.accept)
+}
+
+func (p *regexpParser) peek() byte {
+	if p.pos >= len(p.pattern) {
+		return 0
+	}
+	return p.pattern[p.pos]
+}
+
+func (p *regexpParser) parseAlternation() fragment {
+	left := p.parseConcat()
+	for p.peek() == '|' {
+		p.pos++
+		right := p.parseConcat()
+		start := p.nfa.newState()
+		accept := p.nfa.newState()
+		p.nfa.addEpsilon(start, left.start)
+		p.nfa.addEpsilon(start, right.start)
+		p.nfa.addEpsilon(left.accept, accept)
+		p.nfa.addEpsilon(right.accept, accept)
+		left = fragment{start, accept}
+	}
+	return left
+}
+
+func (p *regexpParser) parseConcat() fragment {
+	start := p.nfa.newState()
+	accept := start
+	first := true
+	for p.pos < len(p.pattern) && p.peek() != '|' && p.peek() != ')' {
+		f := p.parseRepeat()
+		if first {
+			p.nfa.addEpsilon(start, f.start)
+			first = false
+		} else {
+			p.nfa.addEpsilon(accept, f.start)
+		}
+		accept = f.accept
+	}
+	if first {
+		accept = p.nfa.newState()
+		p.nfa.addEpsilon(start, accept)
+	}
+	return fragment{start, accept}
+}
+
+func (p *regexpParser) parseRepeat() fragment {
+	f := p.parseAtom()
+	switch p.peek() {
+	case '*':
+		p.pos++
+		start := p.nfa.newState()
+		accept := p.nfa.newState()
+		p.nfa.addEpsilon(start, f.start)
+		p.nfa.addEpsilon(start, accept)
+		p.nfa.addEpsilon(f.accept, f.start)
+		p.nfa.addEpsilon(f.accept, accept)
+		return fragment{start, accept}
+	case '+':
+		p.pos++
+		accept := p.nfa.newState()
+		p.nfa.addEpsilon(f.accept, f.start)
+		p.nfa.addEpsilon(f.accept, accept)
+		return fragment{f.start, accept}
+	case '?':
+		p.pos++
+		start := p.nfa.newState()
+		p.nfa.addEpsilon(start, f.start)
+		p.nfa.addEpsilon(start, f.accept)
+		return fragment{start, f.accept}
+	default:
+		return f
+	}
+}
+
+func (p *regexpParser) parseAtom() fragment {
+	switch p.peek() {
+	case '(':
+		p.pos++
+		f := p.parseAlternation()
+		if p.peek() == ')' {
+			p.pos++
+		}
+		return f
+	case '.':
+		p.pos++
+		return p.literalRange(0, 0xFF)
+	case '[':
+		return p.parseClass()
+	default:
+		c := p.peek()
+		p.pos++
+		return p.literalRange(c, c)
+	}
+}
+
+func (p *regexpParser) literalRange(min, max byte) fragment {
+	start := p.nfa.newState()
+	accept := p.nfa.newState()
+	p.nfa.addEdge(start, min, max, accept)
+	return fragment{start, accept}
+}
+
+func (p *regexpParser) parseClass() fragment {
+	p.pos++ // consume '['
+	negate := false
+	if p.peek() == '^' {
+		negate = true
+		p.pos++
+	}
+	var ranges [][2]byte
+	for p.pos < len(p.pattern) && p.peek() != ']' {
+		lo := p.peek()
+		p.pos++
+		hi := lo
+		if p.peek() == '-' && p.pos+1 < len(p.pattern) && p.pattern[p.pos+1] != ']' {
+			p.pos++
+			hi = p.peek()
+			p.pos++
+		}
+		ranges = append(ranges, [2]byte{lo, hi})
+	}
+	if p.peek() == ']' {
+		p.pos++
+	}
+	start := p.nfa.newState()
+	accept := p.nfa.newState()
+	if negate {
+		for _, r := range invertRanges(ranges) {
+			p.nfa.addEdge(start, r.lo, r.hi, accept)
+		}
+	} else {
+		for _, r := range ranges {
+			p.nfa.addEdge(start, r[0], r[1], accept)
+		}
+	}
+	return fragment{start, accept}
+}
+
+func invertRanges(ranges [][2]byte) (out []struct{ lo, hi byte }) {
+	next := byte(0)
+	for _, r := range ranges {
+		if r[0] > next {
+			out = append(out, struct{ lo, hi byte }{next, r[0] - 1})
+		}
+		if r[1] >= next {
+			next = r[1] + 1
+		}
+	}
+	if next != 0 {
+		out = append(out, struct{ lo, hi byte }{next, 0xFF})
+	}
+	return out
+}
+
+// determinize runs subset construction over the NFA, producing a DFA
+// Automaton. A subset is accepting iff it epsilon-reaches acceptState.
+func determinize(nfa *nfaBuilder, start, acceptState int) *Automaton {
+	closure := func(states []int) []int {
+		seen := make(map[int]bool)
+		stack := append([]int(nil), states...)
+		for len(stack) > 0 {
+			s := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if seen[s] {
+				continue
+			}
+			seen[s] = true
+			for _, e := range nfa.states[s].epsilon {
+				if e >= 0 && !seen[e] {
+					stack = append(stack, e)
+				}
+			}
+		}
+		out := make([]int, 0, len(seen))
+		for s := range seen {
+			out = append(out, s)
+		}
+		return out
+	}
+	key := func(states []int) string {
+		seen := make([]bool, len(nfa.states))
+		for _, s := range states {
+			seen[s] = true
+		}
+		return string(boolsToBytes(seen))
+	}
+
+	bld := NewBuilder()
+	startSet := closure([]int{start})
+	startDFA := bld.NewState()
+	bld.SetInitialState(startDFA)
+	seenSets := map[string]int{key(startSet): startDFA}
+	bld.SetAccept(startDFA, containsInt(startSet, acceptState))
+
+	type pending struct {
+		state int
+		set   []int
+	}
+	queue := []pending{{startDFA, startSet}}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		// Gather the distinct byte boundaries among outgoing edges so we
+		// emit the minimum number of (possibly multi-byte) ranges.
+		boundaries := map[int]bool{0: true, 256: true}
+		for _, s := range cur.set {
+			for _, e := range nfa.states[s].edges {
+				boundaries[int(e.min)] = true
+				boundaries[int(e.max)+1] = true
+			}
+		}
+		var points []int
+		for b := range boundaries {
+			points = append(points, b)
+		}
+		sortInts(points)
+		for i := 0; i+1 < len(points); i++ {
+			lo, hi := points[i], points[i+1]-1
+			if lo > hi || lo > 255 {
+				continue
+			}
+			var dest []int
+			for _, s := range cur.set {
+				for _, e := range nfa.states[s].edges {
+					if int(e.min) <= lo && hi <= int(e.max) {
+						dest = append(dest, e.dest)
+					}
+				}
+			}
+			if len(dest) == 0 {
+				continue
+			}
+			destSet := closure(dest)
+			k := key(destSet)
+			destState, ok := seenSets[k]
+			if !ok {
+				destState = bld.NewState()
+				seenSets[k] = destState
+				bld.SetAccept(destState, containsInt(destSet, acceptState))
+				queue = append(queue, pending{destState, destSet})
+			}
+			bld.AddTransition(cur.state, byte(lo), byte(hi), destState)
+		}
+	}
+	return bld.Finish()
+}
+
+func containsInt(xs []int, x int) bool {
+	for _, v := range xs {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
+
+func sortInts(xs []int) {
+	for i := 1; i < len(xs); i++ {
+		for j := i; j > 0 && xs[j-1] > xs[j]; j-- {
+			xs[j-1], xs[j] = xs[j], xs[j-1]
+		}
+	}
+}
+
+func boolsToBytes(bs []bool) []byte {
+	out := make([]byte, len(bs))
+	for i, b := range bs {
+		if b {
+			out[i] = 1
+		}
+	}
+	return out
+}
+
+// PrefixAutomaton builds an Automaton accepting exactly the byte strings
+// beginning with prefix.
+func PrefixAutomaton(prefix []byte) *Automaton {
+	bld := NewBuilder()
+	start := bld.NewState()
+	bld.SetInitialState(start)
+	cur := start
+	for _, b := range prefix {
+		next := bld.NewState()
+		bld.AddTransition(cur, b, b, next)
+		cur = next
+	}
+	any := bld.NewState()
+	bld.SetAccept(any, true)
+	bld.AddTransition(any, 0, 0xFF, any)
+	bld.AddTransition(cur, 0, 0xFF, any)
+	bld.SetAccept(cur, true)
+	return bld.Finish()
+}
+
+// WildcardAutomaton compiles a classic Lucene wildcard pattern (`*`
+// matches any run of bytes, `?` matches exactly one byte, everything else
+// is literal) by translating it to the regexp grammar (`*` -> `.*`,
+// `?` -> `.`) and reusing ParseRegexp.
+func WildcardAutomaton(pattern string) *Automaton {
+	var re []byte
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			re = append(re, '.', '*')
+		case '?':
+			re = append(re, '.')
+		case '.', '|', '(', ')', '[', '+':
+			// Escape regexp metacharacters that are literal in wildcard
+			// syntax; our parser has no escape syntax of its own, so
+			// fall back to an explicit single-char class. ']' is already
+			// literal outside a class (and "[]]" would be an empty class).
+			re = append(re, '[', c, ']')
+		default:
+			re = append(re, c)
+		}
+	}
+	return ParseRegexp(string(re))
+}
@@ -0,0 +1,82 @@
+package automaton
+
+import "testing"
+
+func runCases(t *testing.T, a *Automaton, accept, reject []string) {
+	t.Helper()
+	for _, s := range accept {
+		if !a.Run([]byte(s)) {
+			t.Errorf("Run(%q) = false, want true", s)
+		}
+	}
+	for _, s := range reject {
+		if a.Run([]byte(s)) {
+			t.Errorf("Run(%q) = true, want false", s)
+		}
+	}
+}
+
+func TestParseRegexpLiteralAndConcat(t *testing.T) {
+	a := ParseRegexp("cat")
+	runCases(t, a, []string{"cat"}, []string{"ca", "cats", "dog", ""})
+}
+
+func TestParseRegexpAlternation(t *testing.T) {
+	a := ParseRegexp("cat|dog")
+	runCases(t, a, []string{"cat", "dog"}, []string{"catdog", "do", ""})
+}
+
+func TestParseRegexpStar(t *testing.T) {
+	a := ParseRegexp("ab*c")
+	runCases(t, a, []string{"ac", "abc", "abbbc"}, []string{"a", "abd", "abcc"})
+}
+
+func TestParseRegexpPlus(t *testing.T) {
+	a := ParseRegexp("ab+c")
+	runCases(t, a, []string{"abc", "abbc"}, []string{"ac", "a"})
+}
+
+func TestParseRegexpOptional(t *testing.T) {
+	a := ParseRegexp("colou?r")
+	runCases(t, a, []string{"color", "colour"}, []string{"colouur", "colur"})
+}
+
+func TestParseRegexpDotMatchesAnyByte(t *testing.T) {
+	a := ParseRegexp("c.t")
+	runCases(t, a, []string{"cat", "cot", "c t"}, []string{"ct", "caat"})
+}
+
+func TestParseRegexpCharClass(t *testing.T) {
+	a := ParseRegexp("[abc]at")
+	runCases(t, a, []string{"aat", "bat", "cat"}, []string{"dat", "at"})
+}
+
+func TestParseRegexpCharClassRange(t *testing.T) {
+	a := ParseRegexp("[a-c]at")
+	runCases(t, a, []string{"aat", "bat", "cat"}, []string{"dat"})
+}
+
+func TestParseRegexpNegatedCharClass(t *testing.T) {
+	a := ParseRegexp("[^abc]at")
+	runCases(t, a, []string{"dat", "zat"}, []string{"aat", "bat", "cat"})
+}
+
+func TestParseRegexpGrouping(t *testing.T) {
+	a := ParseRegexp("(ab)+c")
+	runCases(t, a, []string{"abc", "ababc"}, []string{"ac", "abbc"})
+}
+
+func TestPrefixAutomatonMatchesAnySuffix(t *testing.T) {
+	a := PrefixAutomaton([]byte("foo"))
+	runCases(t, a, []string{"foo", "foobar", "food"}, []string{"fo", "barfoo", ""})
+}
+
+func TestWildcardAutomatonStarAndQuestionMark(t *testing.T) {
+	a := WildcardAutomaton("f?o*")
+	runCases(t, a, []string{"foo", "fzo", "fzobar"}, []string{"fo", "oof"})
+}
+
+func TestWildcardAutomatonEscapesRegexpMetacharacters(t *testing.T) {
+	a := WildcardAutomaton("a.b")
+	runCases(t, a, []string{"a.b"}, []string{"axb", "ab"})
+}